@@ -10,19 +10,43 @@
 package siftool
 
 import (
+	"context"
+	"errors"
 	"os"
 
+	"github.com/apptainer/sif/v2/pkg/remote"
 	"github.com/apptainer/sif/v2/pkg/sif"
 )
 
-// withFileImage calls fn with a FileImage loaded from path.
-func withFileImage(path string, writable bool, fn func(*sif.FileImage) error) error {
+var errRemoteImageWritable = errors.New("remote images cannot be modified")
+
+// loadFileImage loads a FileImage from path, which may be a local path or an HTTP(S) URL.
+func loadFileImage(path string, writable bool) (*sif.FileImage, error) {
+	if remote.IsURL(path) {
+		if writable {
+			return nil, errRemoteImageWritable
+		}
+
+		return remote.LoadContainer(context.Background(), path)
+	}
+
 	flag := os.O_RDONLY
 	if writable {
 		flag = os.O_RDWR
 	}
 
-	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(flag))
+	// Journal modifications, so that an interrupted command does not leave the image corrupted, and
+	// lock the image, so that concurrent commands do not modify it while in use.
+	return sif.LoadContainerFromPath(path,
+		sif.OptLoadWithFlag(flag),
+		sif.OptLoadWithJournal(writable),
+		sif.OptLoadWithLock(true),
+	)
+}
+
+// withFileImage calls fn with a FileImage loaded from path.
+func withFileImage(path string, writable bool, fn func(*sif.FileImage) error) error {
+	f, err := loadFileImage(path, writable)
 	if err != nil {
 		return err
 	}
@@ -13,6 +13,7 @@
 package siftool
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -50,7 +51,9 @@ func writeHeader(w io.Writer, f *sif.FileImage) error {
 
 	fmt.Fprintf(tw, "Version:\t%v\n", f.Version())
 
-	if arch := f.PrimaryArch(); arch != "unknown" {
+	if archs := f.PrimaryArchs(); len(archs) > 1 {
+		fmt.Fprintf(tw, "Primary Architectures:\t%v\n", strings.Join(archs, ", "))
+	} else if arch := f.PrimaryArch(); arch != "unknown" {
 		fmt.Fprintf(tw, "Primary Architecture:\t%v\n", arch)
 	}
 
@@ -266,3 +269,31 @@ func (a *App) Dump(path string, id uint32) error {
 		return err
 	})
 }
+
+var errCheckFailed = errors.New("image failed consistency check")
+
+// Check checks the structural consistency of a SIF file, and outputs any inconsistencies found. If
+// an inconsistency of severity sif.SeverityError is found, an error wrapping errCheckFailed is
+// returned.
+func (a *App) Check(path string) error {
+	return withFileImage(path, false, func(f *sif.FileImage) error {
+		fs, err := f.Check()
+		if err != nil {
+			return err
+		}
+
+		var errs int
+		for _, finding := range fs {
+			fmt.Fprintln(a.opts.out, finding)
+
+			if finding.Severity == sif.SeverityError {
+				errs++
+			}
+		}
+
+		if errs > 0 {
+			return fmt.Errorf("%w: %v error(s) found", errCheckFailed, errs)
+		}
+		return nil
+	})
+}
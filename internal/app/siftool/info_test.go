@@ -8,16 +8,29 @@ package siftool
 import (
 	"bytes"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/apptainer/sif/v2/pkg/remote"
 	"github.com/apptainer/sif/v2/pkg/sif"
 	"github.com/sebdah/goldie/v2"
 )
 
 var corpus = filepath.Join("..", "..", "..", "test", "images")
 
+// corpusURL starts an HTTP server that serves the test corpus, and returns its URL.
+func corpusURL(t *testing.T) string {
+	t.Helper()
+
+	s := httptest.NewServer(http.FileServer(http.Dir(corpus)))
+	t.Cleanup(s.Close)
+
+	return s.URL
+}
+
 func Test_readableSize(t *testing.T) {
 	tests := []struct {
 		name string
@@ -76,6 +89,8 @@ func Test_readableSize(t *testing.T) {
 
 //nolint:dupl
 func TestApp_Header(t *testing.T) {
+	url := corpusURL(t)
+
 	tests := []struct {
 		name    string
 		path    string
@@ -126,6 +141,15 @@ func TestApp_Header(t *testing.T) {
 			name: "OneGroup",
 			path: filepath.Join(corpus, "one-group.sif"),
 		},
+		{
+			name: "OneGroupURL",
+			path: url + "/one-group.sif",
+		},
+		{
+			name:    "NotExistURL",
+			path:    url + "/not-exist.sif",
+			wantErr: &remote.UnexpectedStatusError{StatusCode: http.StatusNotFound},
+		},
 		{
 			name: "OneGroupSignedLegacy",
 			path: filepath.Join(corpus, "one-group-signed-legacy.sif"),
@@ -162,6 +186,10 @@ func TestApp_Header(t *testing.T) {
 			name: "TwoGroupsSignedPGP",
 			path: filepath.Join(corpus, "two-groups-signed-pgp.sif"),
 		},
+		{
+			name: "TwoArchs",
+			path: filepath.Join(corpus, "two-archs.sif"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -186,6 +214,8 @@ func TestApp_Header(t *testing.T) {
 
 //nolint:dupl
 func TestApp_List(t *testing.T) {
+	url := corpusURL(t)
+
 	tests := []struct {
 		name    string
 		path    string
@@ -236,6 +266,15 @@ func TestApp_List(t *testing.T) {
 			name: "OneGroup",
 			path: filepath.Join(corpus, "one-group.sif"),
 		},
+		{
+			name: "OneGroupURL",
+			path: url + "/one-group.sif",
+		},
+		{
+			name:    "NotExistURL",
+			path:    url + "/not-exist.sif",
+			wantErr: &remote.UnexpectedStatusError{StatusCode: http.StatusNotFound},
+		},
 		{
 			name: "OneGroupSignedLegacy",
 			path: filepath.Join(corpus, "one-group-signed-legacy.sif"),
@@ -272,6 +311,10 @@ func TestApp_List(t *testing.T) {
 			name: "TwoGroupsSignedPGP",
 			path: filepath.Join(corpus, "two-groups-signed-pgp.sif"),
 		},
+		{
+			name: "TwoArchs",
+			path: filepath.Join(corpus, "two-archs.sif"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -427,3 +470,59 @@ func TestApp_Dump(t *testing.T) {
 		})
 	}
 }
+
+func TestApp_Check(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(corpus, "one-group.sif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := filepath.Join(t.TempDir(), "truncated.sif")
+	if err := os.WriteFile(truncated, b[:len(b)-1], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{
+			name:    "NotExist",
+			path:    "not-exist.sif",
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name: "OneGroup",
+			path: filepath.Join(corpus, "one-group.sif"),
+		},
+		{
+			name: "TwoArchs",
+			path: filepath.Join(corpus, "two-archs.sif"),
+		},
+		{
+			name:    "Truncated",
+			path:    truncated,
+			wantErr: errCheckFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			a, err := New(OptAppOutput(&b))
+			if err != nil {
+				t.Fatalf("failed to create app: %v", err)
+			}
+
+			if got, want := a.Check(tt.path), tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if !errors.Is(tt.wantErr, os.ErrNotExist) {
+				g := goldie.New(t, goldie.WithTestNameForDir(true))
+				g.Assert(t, tt.name, b.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build unix
+
+package siftool
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+func TestApp_Locked(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Add(path, sif.DataGeneric, bytes.NewReader([]byte{0xde, 0xad})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold a shared lock, as a concurrent reader would.
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY), sif.OptLoadWithLock(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+
+	go func() {
+		done <- a.SetName(path, 1, "name")
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("modification completed while image locked (error %v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -13,14 +13,19 @@
 package siftool
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"slices"
 
 	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/google/uuid"
 )
 
 // New creates a new empty SIF file.
 func (*App) New(path string) error {
-	f, err := sif.CreateContainerAtPath(path)
+	f, err := sif.CreateContainerAtPath(path, sif.OptCreateWithLock(true))
 	if err != nil {
 		return err
 	}
@@ -53,3 +58,261 @@ func (*App) Setprim(path string, id uint32) error {
 		return f.SetPrimPart(id)
 	})
 }
+
+// Repack defragments the data section of the SIF file.
+func (*App) Repack(path string) error {
+	return withFileImage(path, true, func(f *sif.FileImage) error {
+		return f.Repack()
+	})
+}
+
+// selectObjects returns a selector func that selects data objects with the specified IDs, and data
+// objects in the specified groups. If no IDs or groups are specified, all data objects are
+// selected.
+func selectObjects(ids, groupIDs []uint32) sif.DescriptorSelectorFunc {
+	return func(d sif.Descriptor) (bool, error) {
+		if len(ids) == 0 && len(groupIDs) == 0 {
+			return true, nil
+		}
+		return slices.Contains(ids, d.ID()) || slices.Contains(groupIDs, d.GroupID()), nil
+	}
+}
+
+// Copy copies data objects from the SIF file at src to the SIF file at dst. Data objects with the
+// specified IDs, and data objects in the specified groups, are copied. If no IDs or groups are
+// specified, all data objects are copied.
+func (*App) Copy(src, dst string, ids, groupIDs []uint32) error {
+	// Images are locked while in use, so when copying within an image, load it only once.
+	if sameFile(src, dst) {
+		return withFileImage(dst, true, func(f *sif.FileImage) error {
+			_, err := f.CopyObjects(f, selectObjects(ids, groupIDs))
+			return err
+		})
+	}
+
+	return withFileImage(src, false, func(s *sif.FileImage) error {
+		return withFileImage(dst, true, func(f *sif.FileImage) error {
+			_, err := f.CopyObjects(s, selectObjects(ids, groupIDs))
+			return err
+		})
+	})
+}
+
+// Extract writes a new SIF file to dst, containing a subset of the data objects in the SIF file at
+// src. Data objects with the specified IDs, and data objects in the specified groups, are
+// included, unless their ID is in excludeIDs. If no IDs or groups are specified, all data objects
+// other than those in excludeIDs are included. Data objects linked to an included data object are
+// also included.
+func (*App) Extract(src, dst string, ids, groupIDs, excludeIDs []uint32) error {
+	exclude := func(d sif.Descriptor) (bool, error) {
+		return !slices.Contains(excludeIDs, d.ID()), nil
+	}
+
+	if err := checkDistinct(src, dst); err != nil {
+		return err
+	}
+
+	return withFileImage(src, false, func(f *sif.FileImage) error {
+		fp, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o755)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Extract(fp, selectObjects(ids, groupIDs), exclude); err != nil {
+			fp.Close()
+			os.Remove(dst)
+
+			return err
+		}
+
+		return fp.Close()
+	})
+}
+
+// warnSignatures writes a warning if f contains signatures, since these may be invalidated when
+// modifying f.
+func (a *App) warnSignatures(f *sif.FileImage) {
+	if ds, err := f.GetDescriptors(sif.WithDataType(sif.DataSignature)); err == nil && len(ds) > 0 {
+		fmt.Fprintln(a.opts.err, "Warning: image contains signature(s) that may no longer be valid")
+	}
+}
+
+// SetName sets the name of the specified data object in the SIF file.
+func (a *App) SetName(path string, id uint32, name string) error {
+	return withFileImage(path, true, func(f *sif.FileImage) error {
+		if err := f.SetName(id, name); err != nil {
+			return err
+		}
+
+		a.warnSignatures(f)
+		return nil
+	})
+}
+
+// SetGroup sets the group of the specified data object in the SIF file. If groupID is zero, the
+// data object is removed from its group.
+func (a *App) SetGroup(path string, id, groupID uint32) error {
+	return withFileImage(path, true, func(f *sif.FileImage) error {
+		var err error
+		if groupID == 0 {
+			err = f.SetNoGroup(id)
+		} else {
+			err = f.SetGroupID(id, groupID)
+		}
+		if err != nil {
+			return err
+		}
+
+		a.warnSignatures(f)
+		return nil
+	})
+}
+
+// SetLink sets the link of the specified data object in the SIF file. If isGroup is true, linkID
+// is interpreted as a group ID. If linkID is zero, the link is removed.
+func (a *App) SetLink(path string, id, linkID uint32, isGroup bool) error {
+	return withFileImage(path, true, func(f *sif.FileImage) error {
+		var err error
+		switch {
+		case linkID == 0:
+			err = f.SetNoLink(id)
+		case isGroup:
+			err = f.SetLinkedGroupID(id, linkID)
+		default:
+			err = f.SetLinkedID(id, linkID)
+		}
+		if err != nil {
+			return err
+		}
+
+		a.warnSignatures(f)
+		return nil
+	})
+}
+
+// SetLaunchScript sets the launch script of the SIF file.
+func (a *App) SetLaunchScript(path, script string) error {
+	return withFileImage(path, true, func(f *sif.FileImage) error {
+		if err := f.SetLaunchScript(script); err != nil {
+			return err
+		}
+
+		a.warnSignatures(f)
+		return nil
+	})
+}
+
+// SetID sets the unique ID of the SIF file. If id is empty, a random ID is generated.
+func (a *App) SetID(path, id string) error {
+	if id == "" {
+		u, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+		id = u.String()
+	}
+
+	return withFileImage(path, true, func(f *sif.FileImage) error {
+		if err := f.SetID(id); err != nil {
+			return err
+		}
+
+		a.warnSignatures(f)
+		return nil
+	})
+}
+
+var errSameFile = errors.New("source and destination are the same file")
+
+// sameFile reports whether the local paths a and b refer to the same file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(ai, bi)
+}
+
+// checkDistinct returns errSameFile if the local paths src and dst refer to the same file. It is
+// used where dst is created or truncated before src has been fully read, which would otherwise
+// destroy the source.
+func checkDistinct(src, dst string) error {
+	if sameFile(src, dst) {
+		return errSameFile
+	}
+	return nil
+}
+
+// copyFile copies the file at src to dst, creating or truncating dst as required.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := checkDistinct(src, dst); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+
+		return err
+	}
+
+	return out.Close()
+}
+
+// Repair repairs structural inconsistencies in the SIF file at src, and outputs the modifications
+// made. If dst is not empty, src is copied to dst, and the copy is repaired. Otherwise, src is
+// repaired in place.
+func (a *App) Repair(src, dst string) error {
+	path := src
+
+	if dst != "" {
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		path = dst
+	}
+
+	err := withFileImage(path, true, func(f *sif.FileImage) error {
+		fixes, err := f.Repair()
+		if err != nil {
+			return err
+		}
+
+		for _, fix := range fixes {
+			fmt.Fprintln(a.opts.out, fix)
+		}
+
+		if len(fixes) > 0 {
+			a.warnSignatures(f)
+		}
+
+		return nil
+	})
+	if err != nil && dst != "" {
+		os.Remove(dst)
+	}
+
+	return err
+}
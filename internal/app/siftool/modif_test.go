@@ -13,7 +13,9 @@ import (
 	"bytes"
 	"crypto"
 	"errors"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/apptainer/sif/v2/pkg/sif"
@@ -152,3 +154,304 @@ func TestApp_Setprim(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestApp_Repack(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{{0xde, 0xad}, {0xbe, 0xef}} {
+		if err := a.Add(path, sif.DataGeneric, bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.Del(path, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Repack(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_Copy(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+
+	if err := a.New(src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{{0xde, 0xad}, {0xbe, 0xef}} {
+		if err := a.Add(src, sif.DataGeneric, bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	if err := a.New(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Copy(src, dst, []uint32{2}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Copy(src, dst, nil, []uint32{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Copy(src, dst, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Copy(dst, dst, []uint32{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_Extract(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+
+	if err := a.New(src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{{0xde, 0xad}, {0xbe, 0xef}} {
+		if err := a.Add(src, sif.DataGeneric, bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	if err := a.Extract(src, dst, []uint32{2}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Extract(src, dst, nil, nil, []uint32{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Extract(src, dst, nil, nil, []uint32{1, 2}); !errors.Is(err, sif.ErrObjectNotFound) {
+		t.Errorf("got error %v, want %v", err, sif.ErrObjectNotFound)
+	}
+
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+
+	if err := a.Extract(src, src, nil, nil, nil); !errors.Is(err, errSameFile) {
+		t.Errorf("got error %v, want %v", err, errSameFile)
+	}
+
+	if err := a.Check(src); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+func TestApp_SetName(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Add(path, sif.DataGeneric, bytes.NewReader([]byte{0xde, 0xad, 0xbe, 0xef})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetName(path, 1, "name"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_SetGroup(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Add(path, sif.DataGeneric, bytes.NewReader([]byte{0xde, 0xad, 0xbe, 0xef})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetGroup(path, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetGroup(path, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_SetLink(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{{0xde, 0xad}, {0xbe, 0xef}} {
+		if err := a.Add(path, sif.DataGeneric, bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.SetLink(path, 2, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetLink(path, 2, 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetLink(path, 2, 0, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_RemoteImageWritable(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := corpusURL(t) + "/one-group.sif"
+
+	if got, want := a.Del(path, 1), errRemoteImageWritable; !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
+
+func TestApp_SetLaunchScript(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetLaunchScript(path, "#!/usr/bin/env run-singularity\n"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_SetID(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := a.New(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetID(path, "de170c43-36ab-44a8-bca9-1ea1a070a274"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetID(path, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApp_Repair(t *testing.T) {
+	var b bytes.Buffer
+
+	a, err := New(OptAppOutput(&b))
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+
+	if err := a.New(src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{{0xde, 0xad}, {0xbe, 0xef}} {
+		if err := a.Add(src, sif.DataGeneric, bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Truncate(src, fi.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	if err := a.Repair(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := b.String(), "object 2: removed descriptor of data object outside image\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("got output %q, want prefix %q", got, want)
+	}
+
+	if err := a.Check(dst); err != nil {
+		t.Errorf("got error %v after repair, want nil", err)
+	}
+
+	if err := a.Check(src); !errors.Is(err, errCheckFailed) {
+		t.Errorf("got error %v, want %v", err, errCheckFailed)
+	}
+
+	if err := a.Repair(src, src); !errors.Is(err, errSameFile) {
+		t.Errorf("got error %v, want %v", err, errSameFile)
+	}
+
+	if err := a.Repair(src, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Check(src); err != nil {
+		t.Errorf("got error %v after repair, want nil", err)
+	}
+}
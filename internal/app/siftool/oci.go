@@ -0,0 +1,92 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"os"
+
+	"github.com/apptainer/sif/v2/pkg/oci"
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+// OCIImport writes a new SIF file to dst, containing the OCI content at src, which may be an OCI
+// image layout directory, or a tar archive containing an OCI image layout or a 'docker save'
+// image. If deterministic is true, the SIF file is created deterministically.
+func (*App) OCIImport(src, dst string, deterministic bool) error {
+	if err := checkDistinct(src, dst); err != nil {
+		return err
+	}
+
+	ii, err := oci.ImageIndexFromPath(src)
+	if err != nil {
+		return err
+	}
+
+	opts := []sif.CreateOpt{sif.OptCreateWithLock(true)}
+	if deterministic {
+		opts = append(opts, sif.OptCreateDeterministic())
+	}
+
+	f, err := sif.CreateContainerAtPath(dst, opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := oci.AddImageIndex(f, ii); err != nil {
+		f.UnloadContainer()
+		os.Remove(dst)
+
+		return err
+	}
+
+	return f.UnloadContainer()
+}
+
+// OCIExport writes the OCI content of the SIF file at src to dst. If archive is true, dst is a tar
+// archive containing an OCI image layout. Otherwise, dst is an OCI image layout directory, which
+// must not already exist.
+func (*App) OCIExport(src, dst string, archive bool) error {
+	if err := checkDistinct(src, dst); err != nil {
+		return err
+	}
+
+	return withFileImage(src, false, func(f *sif.FileImage) error {
+		if archive {
+			return exportArchive(f, dst)
+		}
+
+		if err := os.Mkdir(dst, 0o755); err != nil {
+			return err
+		}
+
+		if err := oci.WriteLayout(f, dst); err != nil {
+			os.RemoveAll(dst)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// exportArchive writes the OCI content of f to a tar archive at path.
+func exportArchive(f *sif.FileImage, path string) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := oci.WriteLayoutArchive(f, fp); err != nil {
+		fp.Close()
+		os.Remove(path)
+
+		return err
+	}
+
+	return fp.Close()
+}
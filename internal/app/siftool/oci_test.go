@@ -0,0 +1,216 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/oci"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestApp_OCIImport(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	ii, err := random.Index(64, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(t.TempDir(), "layout")
+
+	if _, err := layout.Write(src, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	if err := a.OCIImport(src, dst, true); err != nil {
+		t.Fatal(err)
+	}
+
+	err = withFileImage(dst, false, func(f *sif.FileImage) error {
+		got, err := oci.ImageIndexFromFileImage(f)
+		if err != nil {
+			return err
+		}
+
+		h, err := got.Digest()
+		if err != nil {
+			return err
+		}
+
+		if want, err := ii.Digest(); err != nil {
+			return err
+		} else if h != want {
+			t.Errorf("got digest %v, want %v", h, want)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deterministic imports produce identical images.
+	other := filepath.Join(t.TempDir(), "other")
+
+	if err := a.OCIImport(src, other, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := os.ReadFile(dst); err != nil {
+		t.Fatal(err)
+	} else if c, err := os.ReadFile(other); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(b, c) {
+		t.Error("import not deterministic")
+	}
+
+	// Importing an archive to itself must not destroy it.
+	archive := filepath.Join(t.TempDir(), "image.tar")
+
+	if err := a.OCIExport(dst, archive, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.OCIImport(archive, archive, false); !errors.Is(err, errSameFile) {
+		t.Errorf("got error %v, want %v", err, errSameFile)
+	}
+
+	if _, err := oci.ImageIndexFromPath(archive); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+
+	// A failed import does not leave an image behind.
+	blobs := filepath.Join(src, "blobs", "sha256")
+
+	des, err := os.ReadDir(blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, de := range des {
+		fi, err := de.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(blobs, de.Name()), make([]byte, fi.Size()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt")
+
+	if err := a.OCIImport(src, corrupt, false); err == nil {
+		t.Error("unexpected success")
+	}
+
+	if _, err := os.Stat(corrupt); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestApp_OCIExport(t *testing.T) {
+	a, err := New()
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	ii, err := random.Index(64, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ii.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(t.TempDir(), "layout")
+
+	if _, err := layout.Write(src, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.sif")
+
+	if err := a.OCIImport(src, path, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		archive bool
+	}{
+		{name: "Layout"},
+		{name: "Archive", archive: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "dst")
+
+			if err := a.OCIExport(path, dst, tt.archive); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := oci.ImageIndexFromPath(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if h, err := got.Digest(); err != nil {
+				t.Fatal(err)
+			} else if h != want {
+				t.Errorf("got digest %v, want %v", h, want)
+			}
+		})
+	}
+
+	// Exporting an image to itself must not destroy it.
+	if err := a.OCIExport(path, path, true); !errors.Is(err, errSameFile) {
+		t.Errorf("got error %v, want %v", err, errSameFile)
+	}
+
+	if err := a.Check(path); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+
+	// A failed export does not leave output behind.
+	empty := filepath.Join(t.TempDir(), "empty.sif")
+
+	if err := a.New(empty); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"Error", func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "dst")
+
+			if err := a.OCIExport(empty, dst, tt.archive); !errors.Is(err, sif.ErrObjectNotFound) {
+				t.Errorf("got error %v, want %v", err, sif.ErrObjectNotFound)
+			}
+
+			if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+			}
+		})
+	}
+}
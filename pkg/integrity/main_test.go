@@ -10,6 +10,7 @@
 package integrity
 
 import (
+	"bytes"
 	"crypto"
 	"os"
 	"path/filepath"
@@ -46,6 +47,53 @@ func loadContainer(t *testing.T, path string) *sif.FileImage {
 	return f
 }
 
+// loadContainerReader loads a container from the contents of the file at path, using
+// sif.LoadContainerReader.
+func loadContainerReader(t *testing.T, path string) *sif.FileImage {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := sif.LoadContainerReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := f.UnloadContainer(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return f
+}
+
+// extractContainer loads a container from path, and returns a read-only container containing the
+// data objects selected by fns, extracted using sif.FileImage.Extract.
+func extractContainer(t *testing.T, path string, fns ...sif.DescriptorSelectorFunc) *sif.FileImage {
+	t.Helper()
+
+	var b sif.Buffer
+
+	if err := loadContainer(t, path).Extract(&b, fns...); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := sif.LoadContainerReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := f.UnloadContainer(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return f
+}
+
 // getTestSigner returns a Signer read from the PEM file at path.
 func getTestSigner(t *testing.T, name string, h crypto.Hash) signature.Signer { //nolint:ireturn
 	t.Helper()
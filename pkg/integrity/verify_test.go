@@ -1052,6 +1052,27 @@ func TestVerifier_Verify(t *testing.T) {
 				OptVerifyWithKeyRing(kr),
 			},
 		},
+		{
+			name: "OneGroupSignedDSSEReader",
+			f:    loadContainerReader(t, filepath.Join(corpus, "one-group-signed-dsse.sif")),
+			opts: []VerifierOpt{
+				OptVerifyWithVerifier(ed25519),
+			},
+		},
+		{
+			name: "TwoGroupsSignedDSSEExtracted",
+			f: extractContainer(t, filepath.Join(corpus, "two-groups-signed-dsse.sif"),
+				func(d sif.Descriptor) (bool, error) {
+					if id, isGroup := d.LinkedID(); isGroup {
+						return id == 2, nil
+					}
+					return d.GroupID() == 2, nil
+				},
+			),
+			opts: []VerifierOpt{
+				OptVerifyWithVerifier(ed25519),
+			},
+		},
 		{
 			name: "OneGroupSignedDSSEWithCallback",
 			f:    oneGroupSignedDSSEImage,
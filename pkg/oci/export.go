@@ -0,0 +1,189 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// layoutVersion is the content of the oci-layout file of an exported OCI image layout.
+const layoutVersion = `{"imageLayoutVersion":"1.0.0"}`
+
+// layoutWriter writes the files of an OCI image layout.
+type layoutWriter interface {
+	// writeFile writes a file with the specified name, consisting of size bytes read from r.
+	writeFile(name string, size int64, r io.Reader) error
+}
+
+// dirWriter is a layoutWriter that writes files to a directory.
+type dirWriter struct {
+	dir string
+}
+
+// writeFile writes a file with the specified name, consisting of size bytes read from r. Parent
+// directories are created as required.
+func (w dirWriter) writeFile(name string, _ int64, r io.Reader) error {
+	p := filepath.Join(w.dir, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	fp, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fp, r); err != nil {
+		fp.Close()
+		return err
+	}
+
+	return fp.Close()
+}
+
+// tarWriter is a layoutWriter that writes files to a tar archive.
+type tarWriter struct {
+	tw   *tar.Writer
+	dirs map[string]bool
+}
+
+// writeDir writes an entry for the directory dir and its parents, unless already written.
+func (w *tarWriter) writeDir(dir string) error {
+	if dir == "." || w.dirs[dir] {
+		return nil
+	}
+
+	if err := w.writeDir(path.Dir(dir)); err != nil {
+		return err
+	}
+	w.dirs[dir] = true
+
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0o755,
+		ModTime:  time.Unix(0, 0),
+	})
+}
+
+// writeFile writes a file with the specified name, consisting of size bytes read from r. Entries
+// for parent directories are written as required.
+func (w *tarWriter) writeFile(name string, size int64, r io.Reader) error {
+	if err := w.writeDir(path.Dir(name)); err != nil {
+		return err
+	}
+
+	hdr := tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  time.Unix(0, 0),
+	}
+
+	if err := w.tw.WriteHeader(&hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+// writeBlob writes b to lw. The content of b is verified against its descriptor as it is written.
+func writeBlob(lw layoutWriter, b pendingBlob) error {
+	rc, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	vr, err := newVerifyingReader(rc, b.desc)
+	if err != nil {
+		return err
+	}
+
+	name := path.Join("blobs", b.desc.Digest.Algorithm, b.desc.Digest.Hex)
+
+	return lw.writeFile(name, b.desc.Size, vr)
+}
+
+// exportLayout writes the OCI content of f to lw.
+func exportLayout(lw layoutWriter, f *sif.FileImage) error {
+	ii, err := ImageIndexFromFileImage(f)
+	if err != nil {
+		return err
+	}
+
+	c := blobCollector{seen: make(map[v1.Hash]bool)}
+
+	if err := c.addIndex(ii); err != nil {
+		return err
+	}
+
+	raw, err := ii.RawManifest()
+	if err != nil {
+		return err
+	}
+
+	if err := lw.writeFile(layoutFile, int64(len(layoutVersion)), strings.NewReader(layoutVersion)); err != nil {
+		return err
+	}
+
+	for _, b := range c.blobs {
+		if err := writeBlob(lw, b); err != nil {
+			return err
+		}
+	}
+
+	return lw.writeFile(indexFile, int64(len(raw)), bytes.NewReader(raw))
+}
+
+// WriteLayout writes the OCI content of f to an OCI image layout in the directory dir, which is
+// created if it does not exist. The OCI root index of f is written as the index of the layout, and
+// each blob it references, including the manifests of nested images and indexes, image configs,
+// and layers, is written to the blobs directory. Non-distributable layers are not written.
+//
+// Blob content is streamed from f, and verified against its descriptor as it is written. If f
+// does not contain an OCI root index, an error wrapping sif.ErrObjectNotFound is returned. If a
+// referenced blob is not present in f, an error wrapping ErrBlobNotFound is returned.
+func WriteLayout(f *sif.FileImage, dir string) error {
+	if err := exportLayout(dirWriter{dir}, f); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// WriteLayoutArchive writes the OCI content of f to w, as a tar archive containing an OCI image
+// layout. The content of the layout is as described for WriteLayout. Archive entries are written
+// in a deterministic order, with fixed permissions and modification times, so writing the same
+// image produces identical archives.
+func WriteLayoutArchive(f *sif.FileImage, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	if err := exportLayout(&tarWriter{tw: tw, dirs: make(map[string]bool)}, f); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+func TestWriteLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		ii   v1.ImageIndex
+	}{
+		{
+			name: "Images",
+			ii:   randomIndex(t, 2),
+		},
+		{
+			name: "Nested",
+			ii: mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+				mutate.IndexAddendum{Add: randomIndex(t, 2)},
+				mutate.IndexAddendum{Add: randomIndex(t, 1)},
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFileImage(t, tt.ii)
+
+			want, err := tt.ii.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir := filepath.Join(t.TempDir(), "layout")
+
+			if err := WriteLayout(f, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			// The layout must be readable by other implementations.
+			lp, err := layout.ImageIndexFromPath(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validate.Index(lp); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := lp.Digest(); err != nil {
+				t.Fatal(err)
+			} else if got != want {
+				t.Errorf("got digest %v, want %v", got, want)
+			}
+
+			var b bytes.Buffer
+
+			if err := WriteLayoutArchive(f, &b); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "layout.tar")
+
+			if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			ii, err := ImageIndexFromPath(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validate.Index(ii); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := ii.Digest(); err != nil {
+				t.Fatal(err)
+			} else if got != want {
+				t.Errorf("got digest %v, want %v", got, want)
+			}
+
+			// Archives must be deterministic.
+			var c bytes.Buffer
+
+			if err := WriteLayoutArchive(f, &c); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), c.Bytes()) {
+				t.Error("archive not deterministic")
+			}
+		})
+	}
+}
+
+func TestWriteLayout_Errors(t *testing.T) {
+	ii := randomIndex(t, 1)
+
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	im, err := ii.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls, err := im.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ls[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		f       func(t *testing.T) *sif.FileImage
+		wantErr error
+	}{
+		{
+			name: "NoRootIndex",
+			f: func(t *testing.T) *sif.FileImage {
+				f, _ := createDeterministic(t)
+				return f
+			},
+			wantErr: sif.ErrObjectNotFound,
+		},
+		{
+			name: "MissingLayer",
+			f: func(t *testing.T) *sif.FileImage {
+				f := newFileImage(t, ii)
+
+				if err := f.DeleteObjects(sif.WithOCIBlobDigest(h)); err != nil {
+					t.Fatal(err)
+				}
+
+				return f
+			},
+			wantErr: ErrBlobNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.f(t)
+
+			if err := WriteLayout(f, t.TempDir()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			var b bytes.Buffer
+
+			if err := WriteLayoutArchive(f, &b); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
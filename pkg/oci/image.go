@@ -0,0 +1,117 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+var errLayerNotFound = errors.New("layer not found in manifest")
+
+// image implements partial.CompressedImageCore for an OCI image, with blobs read from a
+// blobSource.
+type image struct {
+	src      blobSource
+	desc     v1.Descriptor
+	raw      []byte
+	manifest *v1.Manifest
+}
+
+// newImage returns an image for the image described by desc, with manifest content raw.
+func newImage(src blobSource, desc v1.Descriptor, raw []byte) (*image, error) {
+	m, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return &image{
+		src:      src,
+		desc:     desc,
+		raw:      raw,
+		manifest: m,
+	}, nil
+}
+
+// MediaType returns the media type of the image manifest.
+func (im *image) MediaType() (types.MediaType, error) {
+	if mt := im.manifest.MediaType; mt != "" {
+		return mt, nil
+	}
+	return im.desc.MediaType, nil
+}
+
+// Descriptor returns a descriptor for the image manifest.
+func (im *image) Descriptor() (*v1.Descriptor, error) {
+	return im.desc.DeepCopy(), nil
+}
+
+// RawManifest returns the serialized bytes of the image manifest.
+func (im *image) RawManifest() ([]byte, error) {
+	return bytes.Clone(im.raw), nil
+}
+
+// RawConfigFile returns the serialized bytes of the image config.
+func (im *image) RawConfigFile() ([]byte, error) {
+	return readBlob(im.src, im.manifest.Config)
+}
+
+// LayerByDigest returns the layer or config blob with digest h.
+func (im *image) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) { //nolint:ireturn
+	if im.manifest.Config.Digest == h {
+		return &layer{src: im.src, desc: im.manifest.Config}, nil
+	}
+
+	for _, desc := range im.manifest.Layers {
+		if desc.Digest == h {
+			return &layer{src: im.src, desc: desc}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%v: %w", h, errLayerNotFound)
+}
+
+// layer implements partial.CompressedLayer for an OCI blob read from a blobSource.
+type layer struct {
+	src  blobSource
+	desc v1.Descriptor
+}
+
+// Digest returns the digest of the compressed layer.
+func (l *layer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+// Size returns the size of the compressed layer.
+func (l *layer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+// MediaType returns the media type of the layer.
+func (l *layer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
+
+// Descriptor returns a descriptor for the layer.
+func (l *layer) Descriptor() (*v1.Descriptor, error) {
+	return l.desc.DeepCopy(), nil
+}
+
+// Compressed returns a reader of the compressed layer content, which is read on demand. If the
+// layer content is not available, as may be the case for non-distributable layers, an error
+// wrapping ErrBlobNotFound is returned.
+func (l *layer) Compressed() (io.ReadCloser, error) {
+	return l.src.openBlob(l.desc)
+}
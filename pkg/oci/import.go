@@ -0,0 +1,232 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+var (
+	errRootIndexExists      = errors.New("image already contains an OCI root index")
+	errUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// pendingBlob describes a blob to be added to an image.
+type pendingBlob struct {
+	desc v1.Descriptor
+	open func() (io.ReadCloser, error)
+}
+
+// bytesOpener returns a function that opens a reader of b.
+func bytesOpener(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+// blobCollector accumulates the blobs to be added to an image, in a deterministic order.
+type blobCollector struct {
+	f     *sif.FileImage
+	seen  map[v1.Hash]bool
+	blobs []pendingBlob
+}
+
+// add records the blob described by desc, which is read using open, unless a blob with the same
+// digest has already been recorded, or is present in the image. If c.f is nil, the latter check is
+// skipped.
+func (c *blobCollector) add(desc v1.Descriptor, open func() (io.ReadCloser, error)) error {
+	if c.seen[desc.Digest] {
+		return nil
+	}
+	c.seen[desc.Digest] = true
+
+	if c.f != nil {
+		if _, err := blobDescriptor(c.f, desc.Digest); err == nil {
+			return nil
+		} else if !errors.Is(err, ErrBlobNotFound) {
+			return err
+		}
+	}
+
+	c.blobs = append(c.blobs, pendingBlob{desc, open})
+	return nil
+}
+
+// addIndex records the blobs referenced by ii, including the manifests of nested images and
+// indexes. Blobs are recorded before any manifest that references them.
+func (c *blobCollector) addIndex(ii v1.ImageIndex) error {
+	m, err := ii.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	for _, desc := range m.Manifests {
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := ii.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+
+			if err := c.addIndex(child); err != nil {
+				return err
+			}
+
+			b, err := child.RawManifest()
+			if err != nil {
+				return err
+			}
+
+			if err := c.add(desc, bytesOpener(b)); err != nil {
+				return err
+			}
+
+		case desc.MediaType.IsImage():
+			im, err := ii.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+
+			if err := c.addImage(desc, im); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("%v: %w: %v", desc.Digest, errUnsupportedMediaType, desc.MediaType)
+		}
+	}
+
+	return nil
+}
+
+// addImage records the layers and config of im, followed by its manifest, which is described by
+// desc. Non-distributable layers are not recorded.
+func (c *blobCollector) addImage(desc v1.Descriptor, im v1.Image) error {
+	m, err := im.Manifest()
+	if err != nil {
+		return err
+	}
+
+	for _, ld := range m.Layers {
+		if !ld.MediaType.IsDistributable() {
+			continue
+		}
+
+		l, err := im.LayerByDigest(ld.Digest)
+		if err != nil {
+			return err
+		}
+
+		if err := c.add(ld, l.Compressed); err != nil {
+			return err
+		}
+	}
+
+	b, err := im.RawConfigFile()
+	if err != nil {
+		return err
+	}
+
+	if err := c.add(m.Config, bytesOpener(b)); err != nil {
+		return err
+	}
+
+	if b, err = im.RawManifest(); err != nil {
+		return err
+	}
+
+	return c.add(desc, bytesOpener(b))
+}
+
+// addBlob stages the addition of b to an image using tx. The content of b is verified against its
+// descriptor as it is written.
+func addBlob(tx *sif.Tx, b pendingBlob) error {
+	rc, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	vr, err := newVerifyingReader(rc, b.desc)
+	if err != nil {
+		return err
+	}
+
+	di, err := sif.NewDescriptorInput(sif.DataOCIBlob, vr,
+		sif.OptOCIBlobDigest(b.desc.Digest),
+		sif.OptObjectSize(b.desc.Size),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.AddObject(di)
+}
+
+// AddImageIndex adds the OCI content of ii to f. The index manifest of ii is added as a data
+// object of type sif.DataOCIRootIndex. Each blob referenced by ii, including the manifests of
+// nested images and indexes, image configs, and layers, is added as a data object of type
+// sif.DataOCIBlob. Non-distributable layers are not added.
+//
+// Blobs are deduplicated by digest, and blobs already present in f are not added again. Blobs are
+// added in the order they are referenced by ii, with each blob preceding any manifest that
+// references it, so adding the same index to images created with sif.OptCreateDeterministic
+// produces identical images.
+//
+// The content of each blob is verified against its descriptor as it is added. The modifications
+// are made using f.Batch, so if an error occurs, f is left unchanged.
+//
+// An image may contain only one OCI root index. If f already contains a root index, an error is
+// returned.
+func AddImageIndex(f *sif.FileImage, ii v1.ImageIndex) error {
+	if _, err := f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex)); err == nil {
+		return fmt.Errorf("%w", errRootIndexExists)
+	} else if !errors.Is(err, sif.ErrObjectNotFound) && !errors.Is(err, sif.ErrNoObjects) {
+		return fmt.Errorf("%w", err)
+	}
+
+	c := blobCollector{
+		f:    f,
+		seen: make(map[v1.Hash]bool),
+	}
+
+	if err := c.addIndex(ii); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	raw, err := ii.RawManifest()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := f.Batch(func(tx *sif.Tx) error {
+		for _, b := range c.blobs {
+			if err := addBlob(tx, b); err != nil {
+				return err
+			}
+		}
+
+		di, err := sif.NewDescriptorInput(sif.DataOCIRootIndex, bytes.NewReader(raw))
+		if err != nil {
+			return err
+		}
+
+		return tx.AddObject(di)
+	}, sif.OptBatchWithDescriptorGrowth(int64(len(c.blobs)+1))); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
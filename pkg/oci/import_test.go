@@ -0,0 +1,215 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// createDeterministic returns a new, empty FileImage created with sif.OptCreateDeterministic,
+// along with its backing storage.
+func createDeterministic(t *testing.T) (*sif.FileImage, *sif.Buffer) {
+	t.Helper()
+
+	b := sif.NewBuffer(nil)
+
+	f, err := sif.CreateContainer(b, sif.OptCreateDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.UnloadContainer() })
+
+	return f, b
+}
+
+// countBlobs returns the number of OCI blobs in f.
+func countBlobs(t *testing.T, f *sif.FileImage) int {
+	t.Helper()
+
+	ds, err := f.GetDescriptors(sif.WithDataType(sif.DataOCIBlob))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return len(ds)
+}
+
+func TestAddImageIndex(t *testing.T) {
+	im := randomImage(t)
+
+	// Each random image consists of two layers, a config, and a manifest.
+	tests := []struct {
+		name      string
+		ii        v1.ImageIndex
+		wantBlobs int
+	}{
+		{
+			name:      "Images",
+			ii:        randomIndex(t, 2),
+			wantBlobs: 8,
+		},
+		{
+			name: "Nested",
+			ii: mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+				mutate.IndexAddendum{Add: randomIndex(t, 2)},
+				mutate.IndexAddendum{Add: randomIndex(t, 1)},
+			),
+			wantBlobs: 14,
+		},
+		{
+			name: "Duplicates",
+			ii: mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+				mutate.IndexAddendum{Add: im},
+				mutate.IndexAddendum{Add: im},
+			),
+			wantBlobs: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, b := createDeterministic(t)
+
+			if err := AddImageIndex(f, tt.ii); err != nil {
+				t.Fatal(err)
+			}
+
+			ii, err := ImageIndexFromFileImage(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validate.Index(ii); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := ii.Digest(); err != nil {
+				t.Fatal(err)
+			} else if want, err := tt.ii.Digest(); err != nil {
+				t.Fatal(err)
+			} else if got != want {
+				t.Errorf("got digest %v, want %v", got, want)
+			}
+
+			if got, want := countBlobs(t, f), tt.wantBlobs; got != want {
+				t.Errorf("got %v blobs, want %v", got, want)
+			}
+
+			// Output must be deterministic.
+			g, c := createDeterministic(t)
+
+			if err := AddImageIndex(g, tt.ii); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), c.Bytes()) {
+				t.Error("output not deterministic")
+			}
+		})
+	}
+}
+
+func TestAddImageIndex_ExistingBlobs(t *testing.T) {
+	im := randomImage(t)
+
+	raw, err := im.RawConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := createDeterministic(t)
+
+	di, err := sif.NewDescriptorInput(sif.DataOCIBlob, bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.AddObject(di); err != nil {
+		t.Fatal(err)
+	}
+
+	ii := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+		mutate.IndexAddendum{Add: im},
+	)
+
+	if err := AddImageIndex(f, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := countBlobs(t, f), 4; got != want {
+		t.Errorf("got %v blobs, want %v", got, want)
+	}
+
+	// Only one root index is permitted.
+	if err := AddImageIndex(f, ii); !errors.Is(err, errRootIndexExists) {
+		t.Errorf("got error %v, want %v", err, errRootIndexExists)
+	}
+}
+
+func TestAddImageIndex_Corrupt(t *testing.T) {
+	ii := randomIndex(t, 1)
+
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	im, err := ii.Image(m.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls, err := im.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ls[1].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := ls[1].Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the content of a layer, retaining its size.
+	dir := writeLayout(t, ii)
+	if err := os.WriteFile(filepath.Join(dir, "blobs", h.Algorithm, h.Hex), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ImageIndexFromPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, b := createDeterministic(t)
+	want := bytes.Clone(b.Bytes())
+
+	if err := AddImageIndex(f, src); !errors.Is(err, errDigestMismatch) {
+		t.Errorf("got error %v, want %v", err, errDigestMismatch)
+	}
+
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Error("image modified")
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ErrNoMatchingImage is the error returned when no image matches.
+var ErrNoMatchingImage = errors.New("no matching image")
+
+// ErrMultipleImages is the error returned when multiple images match.
+var ErrMultipleImages = errors.New("multiple images match")
+
+var errManifestNotFound = errors.New("manifest not found in index")
+
+// imageIndex implements v1.ImageIndex for an OCI index, with blobs read from a blobSource.
+type imageIndex struct {
+	src      blobSource
+	desc     v1.Descriptor
+	raw      []byte
+	manifest *v1.IndexManifest
+}
+
+// newImageIndex returns an imageIndex for the index described by desc, with content raw.
+func newImageIndex(src blobSource, desc v1.Descriptor, raw []byte) (*imageIndex, error) {
+	m, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return &imageIndex{
+		src:      src,
+		desc:     desc,
+		raw:      raw,
+		manifest: m,
+	}, nil
+}
+
+// ImageIndexFromFileImage returns a v1.ImageIndex for the OCI root index stored in f. If f does
+// not contain an OCI root index, an error wrapping sif.ErrObjectNotFound is returned.
+//
+// The manifests of the images and indexes referenced by the returned index are read from f when
+// accessed, and layer content is read from f on demand, so f must remain loaded while the index
+// is in use.
+func ImageIndexFromFileImage(f *sif.FileImage) (v1.ImageIndex, error) { //nolint:ireturn
+	d, err := f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex))
+	if errors.Is(err, sif.ErrNoObjects) {
+		err = sif.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	h, err := d.OCIBlobDigest()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	desc := v1.Descriptor{
+		MediaType: types.OCIImageIndex,
+		Size:      d.Size(),
+		Digest:    h,
+	}
+
+	vr, err := newVerifyingReader(d.GetReader(), desc)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	b, err := io.ReadAll(vr)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	ii, err := newImageIndex(fileImageSource{f}, desc, b)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return ii, nil
+}
+
+// ImageFromFileImage returns a v1.Image for the image selected by m from the OCI root index
+// stored in f. Nested indexes are searched recursively. If m is nil, all images are selected. If
+// no image is selected, an error wrapping ErrNoMatchingImage is returned. If multiple images are
+// selected, an error wrapping ErrMultipleImages is returned.
+//
+// Layer content is read from f on demand, so f must remain loaded while the image is in use.
+func ImageFromFileImage(f *sif.FileImage, m match.Matcher) (v1.Image, error) { //nolint:ireturn
+	ii, err := ImageIndexFromFileImage(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		m = func(v1.Descriptor) bool { return true }
+	}
+
+	ims, err := partial.FindImages(ii, m)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	switch len(ims) {
+	case 0:
+		return nil, fmt.Errorf("%w", ErrNoMatchingImage)
+	case 1:
+		return ims[0], nil
+	default:
+		return nil, fmt.Errorf("%w", ErrMultipleImages)
+	}
+}
+
+// MediaType returns the media type of the index.
+func (ii *imageIndex) MediaType() (types.MediaType, error) {
+	if mt := ii.manifest.MediaType; mt != "" {
+		return mt, nil
+	}
+	return ii.desc.MediaType, nil
+}
+
+// Digest returns the digest of the index manifest.
+func (ii *imageIndex) Digest() (v1.Hash, error) {
+	return ii.desc.Digest, nil
+}
+
+// Size returns the size of the index manifest.
+func (ii *imageIndex) Size() (int64, error) {
+	return ii.desc.Size, nil
+}
+
+// Descriptor returns a descriptor for the index manifest.
+func (ii *imageIndex) Descriptor() (*v1.Descriptor, error) {
+	return ii.desc.DeepCopy(), nil
+}
+
+// IndexManifest returns the index manifest.
+func (ii *imageIndex) IndexManifest() (*v1.IndexManifest, error) {
+	return ii.manifest.DeepCopy(), nil
+}
+
+// RawManifest returns the serialized bytes of the index manifest.
+func (ii *imageIndex) RawManifest() ([]byte, error) {
+	return bytes.Clone(ii.raw), nil
+}
+
+// child returns the descriptor with digest h from the index manifest.
+func (ii *imageIndex) child(h v1.Hash) (v1.Descriptor, error) {
+	for _, desc := range ii.manifest.Manifests {
+		if desc.Digest == h {
+			return desc, nil
+		}
+	}
+	return v1.Descriptor{}, fmt.Errorf("%v: %w", h, errManifestNotFound)
+}
+
+// Image returns the image with manifest digest h, which must be referenced by the index.
+func (ii *imageIndex) Image(h v1.Hash) (v1.Image, error) { //nolint:ireturn
+	desc, err := ii.child(h)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := readBlob(ii.src, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	im, err := newImage(ii.src, desc, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return partial.CompressedToImage(im)
+}
+
+// ImageIndex returns the index with manifest digest h, which must be referenced by the index.
+func (ii *imageIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) { //nolint:ireturn
+	desc, err := ii.child(h)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := readBlob(ii.src, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := newImageIndex(ii.src, desc, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return child, nil
+}
@@ -0,0 +1,293 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+var (
+	errInvalidLayout  = errors.New("invalid OCI image layout")
+	errUnknownArchive = errors.New("archive contains neither an OCI image layout nor a docker image")
+	errUntaggedImage  = errors.New("archive contains multiple images, and not all are tagged")
+)
+
+const (
+	layoutFile = "oci-layout"
+	indexFile  = "index.json"
+
+	dockerManifestFile = "manifest.json"
+)
+
+// layoutSource is a blobSource that reads blobs from an OCI image layout.
+type layoutSource struct {
+	fsys fs.FS
+}
+
+// openBlob returns a reader for the content of the blob described by desc.
+func (s layoutSource) openBlob(desc v1.Descriptor) (io.ReadCloser, error) {
+	f, err := s.fsys.Open(path.Join("blobs", desc.Digest.Algorithm, desc.Digest.Hex))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%v: %w", desc.Digest, ErrBlobNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// imageIndexFromLayout returns an imageIndex for the OCI image layout in fsys.
+func imageIndexFromLayout(fsys fs.FS) (*imageIndex, error) {
+	b, err := fs.ReadFile(fsys, layoutFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidLayout, err)
+	}
+
+	var l struct {
+		Version string `json:"imageLayoutVersion"`
+	}
+
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidLayout, err)
+	}
+
+	if l.Version == "" {
+		return nil, fmt.Errorf("%w: missing image layout version", errInvalidLayout)
+	}
+
+	raw, err := fs.ReadFile(fsys, indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidLayout, err)
+	}
+
+	h, n, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return newImageIndex(layoutSource{fsys}, v1.Descriptor{
+		MediaType: types.OCIImageIndex,
+		Size:      n,
+		Digest:    h,
+	}, raw)
+}
+
+// tarEntry describes a regular file within a tar archive.
+type tarEntry struct {
+	hdr *tar.Header
+	off int64 // Offset of file content within the archive.
+}
+
+// tarFS is an fs.FS that provides access to the regular files in a tar archive. The content of each
+// file is read directly from the archive, which is opened each time a file is opened.
+type tarFS struct {
+	path    string
+	entries map[string]tarEntry
+}
+
+// tarName returns the name of a tar entry as an fs.FS path.
+func tarName(name string) string {
+	return path.Clean(strings.TrimLeft(name, "/"))
+}
+
+// newTarFS returns a tarFS for the tar archive at path.
+func newTarFS(path string) (*tarFS, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// The tar reader seeks over file content, so the position of sr following each header is the
+	// offset of the content of that entry.
+	sr := io.NewSectionReader(fp, 0, fi.Size())
+	tr := tar.NewReader(sr)
+
+	entries := make(map[string]tarEntry)
+	links := make(map[string]string)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			off, err := sr.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+
+			entries[tarName(hdr.Name)] = tarEntry{hdr, off}
+
+		case tar.TypeLink:
+			links[tarName(hdr.Name)] = tarName(hdr.Linkname)
+		}
+	}
+
+	for name, target := range links {
+		if e, ok := entries[target]; ok {
+			entries[name] = e
+		}
+	}
+
+	return &tarFS{path: path, entries: entries}, nil
+}
+
+// has reports whether the archive contains a regular file with the specified name.
+func (t *tarFS) has(name string) bool {
+	_, ok := t.entries[name]
+	return ok
+}
+
+// Open opens the regular file with the specified name.
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	e, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	fp, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tarFile{
+		SectionReader: io.NewSectionReader(fp, e.off, e.hdr.Size),
+		fp:            fp,
+		fi:            e.hdr.FileInfo(),
+	}, nil
+}
+
+// tarFile is a regular file within a tar archive.
+type tarFile struct {
+	*io.SectionReader
+	fp *os.File
+	fi fs.FileInfo
+}
+
+// Stat returns the FileInfo of the file.
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.fi, nil }
+
+// Close closes the archive.
+func (f *tarFile) Close() error { return f.fp.Close() }
+
+// imageIndexFromDockerArchive returns a v1.ImageIndex containing the images in the tar archive at
+// path, which is in the format written by 'docker save'.
+func imageIndexFromDockerArchive(path string) (v1.ImageIndex, error) { //nolint:ireturn
+	opener := func() (io.ReadCloser, error) { return os.Open(path) }
+
+	m, err := tarball.LoadManifest(opener)
+	if err != nil {
+		return nil, err
+	}
+
+	adds := make([]mutate.IndexAddendum, 0, len(m))
+
+	for _, desc := range m {
+		// If the archive contains multiple images, each is selected by tag.
+		var tag *name.Tag
+
+		if len(m) > 1 {
+			if len(desc.RepoTags) == 0 {
+				return nil, errUntaggedImage
+			}
+
+			t, err := name.NewTag(desc.RepoTags[0])
+			if err != nil {
+				return nil, err
+			}
+			tag = &t
+		}
+
+		im, err := tarball.Image(opener, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		adds = append(adds, mutate.IndexAddendum{Add: im})
+	}
+
+	return mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), adds...), nil
+}
+
+// ImageIndexFromPath returns a v1.ImageIndex for the OCI content at path, which may be an OCI
+// image layout directory, a tar archive containing an OCI image layout, or a tar archive in the
+// format written by 'docker save'.
+//
+// The index of an OCI image layout is returned unmodified. The images in a 'docker save' archive
+// are returned in a new OCI index. Blobs are read from path on demand, so path must not be modified
+// while the index is in use.
+func ImageIndexFromPath(path string) (v1.ImageIndex, error) { //nolint:ireturn
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if fi.IsDir() {
+		ii, err := imageIndexFromLayout(os.DirFS(path))
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		return ii, nil
+	}
+
+	t, err := newTarFS(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	switch {
+	case t.has(layoutFile):
+		ii, err := imageIndexFromLayout(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		return ii, nil
+
+	case t.has(dockerManifestFile):
+		ii, err := imageIndexFromDockerArchive(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		return ii, nil
+	}
+
+	return nil, fmt.Errorf("%w", errUnknownArchive)
+}
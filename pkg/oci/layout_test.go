@@ -0,0 +1,217 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// writeLayout writes ii to a new OCI image layout directory, and returns its path.
+func writeLayout(t *testing.T, ii v1.ImageIndex) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "layout")
+
+	if _, err := layout.Write(path, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeTar writes the content of the directory dir to a new tar archive, and returns its path.
+func writeTar(t *testing.T, dir string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "archive.tar")
+
+	fp, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	tw := tar.NewWriter(fp)
+
+	if err := tw.AddFS(os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// randomImage returns a random image.
+func randomImage(t *testing.T) v1.Image { //nolint:ireturn
+	t.Helper()
+
+	im, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return im
+}
+
+// writeDockerArchive writes ims to a new tar archive in the format written by 'docker save', and
+// returns its path.
+func writeDockerArchive(t *testing.T, ims map[string]v1.Image) string {
+	t.Helper()
+
+	refs := make(map[name.Reference]v1.Image)
+
+	for s, im := range ims {
+		tag, err := name.NewTag(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs[tag] = im
+	}
+
+	path := filepath.Join(t.TempDir(), "docker.tar")
+
+	if err := tarball.MultiRefWriteToFile(path, refs); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestImageIndexFromPath(t *testing.T) {
+	ii := randomIndex(t, 2)
+
+	want, err := ii.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantDigest v1.Hash
+		wantImages int
+	}{
+		{
+			name:       "Layout",
+			path:       writeLayout(t, ii),
+			wantDigest: want,
+			wantImages: 2,
+		},
+		{
+			name:       "LayoutArchive",
+			path:       writeTar(t, writeLayout(t, ii)),
+			wantDigest: want,
+			wantImages: 2,
+		},
+		{
+			name: "DockerArchive",
+			path: writeDockerArchive(t, map[string]v1.Image{
+				"example.com/image:1": randomImage(t),
+			}),
+			wantImages: 1,
+		},
+		{
+			name: "DockerArchiveMultiple",
+			path: writeDockerArchive(t, map[string]v1.Image{
+				"example.com/image:1": randomImage(t),
+				"example.com/image:2": randomImage(t),
+			}),
+			wantImages: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ii, err := ImageIndexFromPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validate.Index(ii); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantDigest != (v1.Hash{}) {
+				if got, err := ii.Digest(); err != nil {
+					t.Fatal(err)
+				} else if got != tt.wantDigest {
+					t.Errorf("got digest %v, want %v", got, tt.wantDigest)
+				}
+			}
+
+			m, err := ii.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := len(m.Manifests), tt.wantImages; got != want {
+				t.Errorf("got %v images, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestImageIndexFromPath_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name: "NotExist",
+			path: func(t *testing.T) string {
+				return filepath.Join(t.TempDir(), "missing")
+			},
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			name: "InvalidLayout",
+			path: func(t *testing.T) string {
+				return t.TempDir()
+			},
+			wantErr: errInvalidLayout,
+		},
+		{
+			name: "UnknownArchive",
+			path: func(t *testing.T) string {
+				dir := t.TempDir()
+
+				if err := os.WriteFile(filepath.Join(dir, "file"), []byte("content"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				return writeTar(t, dir)
+			},
+			wantErr: errUnknownArchive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ImageIndexFromPath(tt.path(t)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package oci provides access to OCI content stored in SIF images.
+//
+// A SIF image may contain an OCI root index, stored as a data object of type
+// sif.DataOCIRootIndex, along with the manifests, configs and layers it references, each stored
+// as a data object of type sif.DataOCIBlob. This package exposes such content using the types of
+// the go-containerregistry module, so that it can be used directly with functions such as those
+// of the mutate, remote and tarball packages.
+//
+// Manifests and configs are read when accessed, and layer content is read lazily from the
+// underlying data objects. The FileImage must therefore remain loaded while the returned values
+// are in use.
+//
+// OCI content can be added to a SIF image using AddImageIndex. To read an OCI image layout, or a
+// tar archive written by 'docker save', use ImageIndexFromPath. The OCI content of a SIF image can
+// be written to an OCI image layout using WriteLayout or WriteLayoutArchive.
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrBlobNotFound is the error returned when an OCI blob is not found.
+var ErrBlobNotFound = errors.New("blob not found")
+
+var (
+	errUnsupportedAlgorithm = errors.New("unsupported digest algorithm")
+	errDigestMismatch       = errors.New("digest mismatch")
+	errSizeMismatch         = errors.New("size mismatch")
+)
+
+// blobSource provides access to the content of OCI blobs.
+type blobSource interface {
+	// openBlob returns a reader for the content of the blob described by desc. If the blob is not
+	// found, an error wrapping ErrBlobNotFound is returned.
+	openBlob(desc v1.Descriptor) (io.ReadCloser, error)
+}
+
+// fileImageSource is a blobSource that reads blobs stored in a FileImage.
+type fileImageSource struct {
+	f *sif.FileImage
+}
+
+// blobDescriptor returns the descriptor of the OCI blob with digest h in f. If no such blob is
+// found, an error wrapping ErrBlobNotFound is returned.
+func blobDescriptor(f *sif.FileImage, h v1.Hash) (sif.Descriptor, error) {
+	ds, err := f.GetDescriptors(sif.WithDataType(sif.DataOCIBlob), sif.WithOCIBlobDigest(h))
+	if err != nil && !errors.Is(err, sif.ErrNoObjects) {
+		return sif.Descriptor{}, err
+	}
+
+	if len(ds) == 0 {
+		return sif.Descriptor{}, fmt.Errorf("%v: %w", h, ErrBlobNotFound)
+	}
+
+	// Blobs are content-addressed, so any matching blob will do.
+	return ds[0], nil
+}
+
+// openBlob returns a reader for the content of the blob described by desc.
+func (s fileImageSource) openBlob(desc v1.Descriptor) (io.ReadCloser, error) {
+	d, err := blobDescriptor(s.f, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if got, want := d.Size(), desc.Size; got != want {
+		return nil, fmt.Errorf("%v: %w: got %v bytes, want %v", desc.Digest, errSizeMismatch, got, want)
+	}
+
+	return io.NopCloser(d.GetReader()), nil
+}
+
+// readBlob reads the content of the blob described by desc from src. The content is verified
+// against the size and digest in desc.
+func readBlob(src blobSource, desc v1.Descriptor) ([]byte, error) {
+	rc, err := src.openBlob(desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	vr, err := newVerifyingReader(rc, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(vr)
+}
+
+// verifyingReader is an io.Reader that verifies the content it reads against a descriptor.
+type verifyingReader struct {
+	r    io.Reader
+	desc v1.Descriptor
+	h    hash.Hash
+	n    int64
+}
+
+// newVerifyingReader returns a reader that reads from r. If the content read does not match the
+// size and digest in desc, an error is returned in place of io.EOF.
+func newVerifyingReader(r io.Reader, desc v1.Descriptor) (io.Reader, error) {
+	if desc.Digest.Algorithm != "sha256" {
+		return nil, fmt.Errorf("%v: %w", desc.Digest, errUnsupportedAlgorithm)
+	}
+
+	return &verifyingReader{r: r, desc: desc, h: sha256.New()}, nil
+}
+
+func (vr *verifyingReader) Read(b []byte) (int, error) {
+	n, err := vr.r.Read(b)
+	vr.h.Write(b[:n])
+	vr.n += int64(n)
+
+	if vr.n > vr.desc.Size {
+		return n, fmt.Errorf("%v: %w: more than %v bytes", vr.desc.Digest, errSizeMismatch, vr.desc.Size)
+	}
+
+	if errors.Is(err, io.EOF) {
+		if vr.n != vr.desc.Size {
+			return n, fmt.Errorf("%v: %w: got %v bytes, want %v",
+				vr.desc.Digest, errSizeMismatch, vr.n, vr.desc.Size)
+		}
+
+		if hex.EncodeToString(vr.h.Sum(nil)) != vr.desc.Digest.Hex {
+			return n, fmt.Errorf("%v: %w", vr.desc.Digest, errDigestMismatch)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,316 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// blobInputs returns descriptor inputs for the blobs referenced by ii, including manifests.
+func blobInputs(t *testing.T, ii v1.ImageIndex) []sif.DescriptorInput {
+	t.Helper()
+
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dis []sif.DescriptorInput
+
+	add := func(b []byte) {
+		di, err := sif.NewDescriptorInput(sif.DataOCIBlob, bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dis = append(dis, di)
+	}
+
+	for _, desc := range m.Manifests {
+		if desc.MediaType.IsIndex() {
+			child, err := ii.ImageIndex(desc.Digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dis = append(dis, blobInputs(t, child)...)
+
+			b, err := child.RawManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			add(b)
+
+			continue
+		}
+
+		im, err := ii.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ls, err := im.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, l := range ls {
+			rc, err := l.Compressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			add(b)
+		}
+
+		b, err := im.RawConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(b)
+
+		if b, err = im.RawManifest(); err != nil {
+			t.Fatal(err)
+		}
+		add(b)
+	}
+
+	return dis
+}
+
+// newFileImage returns a FileImage containing ii as its root index.
+func newFileImage(t *testing.T, ii v1.ImageIndex) *sif.FileImage {
+	t.Helper()
+
+	dis := blobInputs(t, ii)
+
+	b, err := ii.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di, err := sif.NewDescriptorInput(sif.DataOCIRootIndex, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := sif.CreateContainer(sif.NewBuffer(nil),
+		sif.OptCreateDeterministic(),
+		sif.OptCreateWithDescriptors(append(dis, di)...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.UnloadContainer() })
+
+	return f
+}
+
+// randomIndex returns an index containing n random images.
+func randomIndex(t *testing.T, n int64) v1.ImageIndex { //nolint:ireturn
+	t.Helper()
+
+	ii, err := random.Index(1024, 2, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mutate.IndexMediaType(ii, types.OCIImageIndex)
+}
+
+func TestImageIndexFromFileImage(t *testing.T) {
+	nested := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex),
+		mutate.IndexAddendum{Add: randomIndex(t, 2)},
+		mutate.IndexAddendum{Add: randomIndex(t, 1)},
+	)
+
+	tests := []struct {
+		name string
+		ii   v1.ImageIndex
+	}{
+		{name: "Empty", ii: mutate.IndexMediaType(empty.Index, types.OCIImageIndex)},
+		{name: "Images", ii: randomIndex(t, 3)},
+		{name: "Nested", ii: nested},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFileImage(t, tt.ii)
+
+			ii, err := ImageIndexFromFileImage(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validate.Index(ii); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ii.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := tt.ii.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Errorf("got digest %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestImageIndexFromFileImage_Errors(t *testing.T) {
+	ii := randomIndex(t, 1)
+
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("NoRootIndex", func(t *testing.T) {
+		f, err := sif.CreateContainer(sif.NewBuffer(nil), sif.OptCreateDeterministic())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ImageIndexFromFileImage(f); !errors.Is(err, sif.ErrObjectNotFound) {
+			t.Errorf("got error %v, want %v", err, sif.ErrObjectNotFound)
+		}
+	})
+
+	t.Run("MissingBlob", func(t *testing.T) {
+		f := newFileImage(t, ii)
+
+		// Remove the image manifest.
+		h := m.Manifests[0].Digest
+		if err := f.DeleteObjects(sif.WithOCIBlobDigest(h)); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ImageIndexFromFileImage(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := got.Image(h); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("got error %v, want %v", err, ErrBlobNotFound)
+		}
+	})
+
+	t.Run("MissingLayer", func(t *testing.T) {
+		f := newFileImage(t, ii)
+
+		im, err := ii.Image(m.Manifests[0].Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ls, err := im.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := ls[0].Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := f.DeleteObjects(sif.WithOCIBlobDigest(h)); err != nil {
+			t.Fatal(err)
+		}
+
+		// The image is accessible, but the content of the missing layer is not.
+		got, err := ImageFromFileImage(f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := got.LayerByDigest(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := l.Compressed(); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("got error %v, want %v", err, ErrBlobNotFound)
+		}
+	})
+}
+
+func TestImageFromFileImage(t *testing.T) {
+	ii := randomIndex(t, 2)
+
+	m, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFileImage(t, ii)
+
+	tests := []struct {
+		name       string
+		m          match.Matcher
+		wantDigest v1.Hash
+		wantErr    error
+	}{
+		{
+			name:    "All",
+			wantErr: ErrMultipleImages,
+		},
+		{
+			name:       "Digest",
+			m:          match.Digests(m.Manifests[1].Digest),
+			wantDigest: m.Manifests[1].Digest,
+		},
+		{
+			name:    "NoMatch",
+			m:       match.Digests(v1.Hash{}),
+			wantErr: ErrNoMatchingImage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im, err := ImageFromFileImage(f, tt.m)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err == nil {
+				if err := validate.Image(im); err != nil {
+					t.Fatal(err)
+				}
+
+				if got, err := im.Digest(); err != nil {
+					t.Fatal(err)
+				} else if got != tt.wantDigest {
+					t.Errorf("got digest %v, want %v", got, tt.wantDigest)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package remote provides read-only access to SIF images served over HTTP.
+//
+// Data is retrieved lazily using HTTP range requests, so only the portions of an image that are
+// accessed are transferred. Retrieved data is cached in fixed-size blocks.
+package remote
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+var (
+	errRangeNotSupported  = errors.New("server does not support range requests")
+	errInvalidBlockSize   = errors.New("block size must be greater than zero")
+	errInvalidCacheBlocks = errors.New("number of cached blocks must be greater than zero")
+	errInvalidRange       = errors.New("invalid Content-Range in response")
+	errResourceModified   = errors.New("resource modified on server")
+	errNegativeOffset     = errors.New("negative offset")
+)
+
+// UnexpectedStatusError records an unexpected HTTP status code in a response from the server.
+type UnexpectedStatusError struct {
+	StatusCode int // HTTP status code.
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status: %v", http.StatusText(e.StatusCode))
+}
+
+// Is compares e against target. If target is a UnexpectedStatusError and matches e or target has
+// a zero value StatusCode, true is returned.
+func (e *UnexpectedStatusError) Is(target error) bool {
+	t, ok := target.(*UnexpectedStatusError)
+	if !ok {
+		return false
+	}
+	return e.StatusCode == t.StatusCode || t.StatusCode == 0
+}
+
+// readerOpts accumulates ReaderAt options.
+type readerOpts struct {
+	client      *http.Client
+	blockSize   int64
+	cacheBlocks int
+	loadOpts    []sif.LoadOpt
+}
+
+// ReaderOpt are used to specify ReaderAt options.
+type ReaderOpt func(*readerOpts) error
+
+// OptReaderHTTPClient specifies c as the HTTP client used to make requests.
+func OptReaderHTTPClient(c *http.Client) ReaderOpt {
+	return func(ro *readerOpts) error {
+		ro.client = c
+		return nil
+	}
+}
+
+// OptReaderBlockSize specifies n as the number of bytes retrieved by each range request.
+func OptReaderBlockSize(n int64) ReaderOpt {
+	return func(ro *readerOpts) error {
+		if n <= 0 {
+			return errInvalidBlockSize
+		}
+		ro.blockSize = n
+		return nil
+	}
+}
+
+// OptReaderCacheBlocks specifies n as the maximum number of blocks to cache.
+func OptReaderCacheBlocks(n int) ReaderOpt {
+	return func(ro *readerOpts) error {
+		if n <= 0 {
+			return errInvalidCacheBlocks
+		}
+		ro.cacheBlocks = n
+		return nil
+	}
+}
+
+// OptReaderLoadOpts specifies opts as the options used to load an image by LoadContainer. The
+// options are ignored by NewReaderAt.
+func OptReaderLoadOpts(opts ...sif.LoadOpt) ReaderOpt {
+	return func(ro *readerOpts) error {
+		ro.loadOpts = append(ro.loadOpts, opts...)
+		return nil
+	}
+}
+
+// block is a cached block of data, which may still be being retrieved.
+type block struct {
+	index int64
+	done  chan struct{} // Closed once retrieval completes.
+	b     []byte
+	err   error
+}
+
+// ReaderAt is an io.ReaderAt that reads from a resource served over HTTP.
+type ReaderAt struct {
+	ctx  context.Context //nolint:containedctx // io.ReaderAt does not accept a context.
+	url  string
+	opts readerOpts
+
+	size int64  // Size of resource.
+	etag string // Entity tag of resource, if supplied by server.
+
+	mu     sync.Mutex
+	lru    *list.List              // Cached blocks, most recently used at front.
+	blocks map[int64]*list.Element // Cached blocks, indexed by block index.
+}
+
+// NewReaderAt returns a ReaderAt that reads from the resource at url, according to opts. The
+// server must support HTTP range requests. Requests are made using ctx.
+//
+// By default, http.DefaultClient is used to make requests. To override this, consider using
+// OptReaderHTTPClient.
+//
+// By default, data is retrieved in blocks of 64 KiB, and up to 256 blocks are cached. To override
+// this, consider using OptReaderBlockSize and OptReaderCacheBlocks.
+func NewReaderAt(ctx context.Context, url string, opts ...ReaderOpt) (*ReaderAt, error) {
+	ro := readerOpts{
+		client:      http.DefaultClient,
+		blockSize:   64 * 1024,
+		cacheBlocks: 256,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ro); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	r := &ReaderAt{
+		ctx:    ctx,
+		url:    url,
+		opts:   ro,
+		size:   -1,
+		lru:    list.New(),
+		blocks: make(map[int64]*list.Element),
+	}
+
+	// Retrieve the first block, which also determines the size of the resource.
+	if _, err := r.getBlock(0); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return r, nil
+}
+
+// Size returns the size of the resource.
+func (r *ReaderAt) Size() int64 { return r.size }
+
+// parseContentRange parses the first byte position and complete length from the value of a
+// Content-Range header.
+func parseContentRange(s string) (int64, int64, error) {
+	s, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, errInvalidRange
+	}
+
+	rng, length, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, errInvalidRange
+	}
+
+	first, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, errInvalidRange
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, errInvalidRange
+	}
+
+	size, err := strconv.ParseInt(length, 10, 64)
+	if err != nil {
+		return 0, 0, errInvalidRange
+	}
+
+	return start, size, nil
+}
+
+// fetchBlock retrieves the block with the specified index from the server.
+func (r *ReaderAt) fetchBlock(index int64) ([]byte, error) {
+	start := index * r.opts.blockSize
+	end := start + r.opts.blockSize
+	if r.size >= 0 {
+		end = min(end, r.size)
+	}
+
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	// Ensure the resource has not been modified since the first block was retrieved. Weak entity
+	// tags cannot be used with If-Range.
+	if r.etag != "" && !strings.HasPrefix(r.etag, "W/") {
+		req.Header.Set("If-Range", r.etag)
+	}
+
+	res, err := r.opts.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if r.size < 0 {
+			return nil, errRangeNotSupported
+		}
+		return nil, errResourceModified
+	default:
+		return nil, &UnexpectedStatusError{StatusCode: res.StatusCode}
+	}
+
+	offset, size, err := parseContentRange(res.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.size < 0 {
+		r.size = size
+		r.etag = res.Header.Get("ETag")
+		end = min(end, size)
+	} else if size != r.size {
+		return nil, errResourceModified
+	}
+
+	if offset != start {
+		return nil, errInvalidRange
+	}
+
+	b := make([]byte, end-start)
+	if _, err := io.ReadFull(res.Body, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// getBlock returns the block with the specified index, from the cache if possible. The block is
+// retrieved without holding r.mu, so reads of other blocks are not delayed by the retrieval, and
+// concurrent reads of the same block wait for a single retrieval.
+func (r *ReaderAt) getBlock(index int64) ([]byte, error) {
+	r.mu.Lock()
+
+	if e, ok := r.blocks[index]; ok {
+		r.lru.MoveToFront(e)
+		r.mu.Unlock()
+
+		blk := e.Value.(*block) //nolint:forcetypeassert // Only blocks are stored in lru.
+		<-blk.done
+		return blk.b, blk.err
+	}
+
+	blk := &block{index: index, done: make(chan struct{})}
+	e := r.lru.PushFront(blk)
+	r.blocks[index] = e
+
+	// Evict the least recently used block, if necessary.
+	if r.lru.Len() > r.opts.cacheBlocks {
+		r.remove(r.lru.Back())
+	}
+
+	r.mu.Unlock()
+
+	blk.b, blk.err = r.fetchBlock(index)
+	close(blk.done)
+
+	// Failed retrievals are not cached, so that subsequent reads retry.
+	if blk.err != nil {
+		r.mu.Lock()
+		if r.blocks[index] == e {
+			r.remove(e)
+		}
+		r.mu.Unlock()
+	}
+
+	return blk.b, blk.err
+}
+
+// remove removes the cached block e. r.mu must be held.
+func (r *ReaderAt) remove(e *list.Element) {
+	r.lru.Remove(e)
+	delete(r.blocks, e.Value.(*block).index) //nolint:forcetypeassert // Only blocks are stored in lru.
+}
+
+// ReadAt reads len(p) bytes from the resource starting at byte offset off. It implements the
+// io.ReaderAt interface.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	n := 0
+
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+
+		b, err := r.getBlock(pos / r.opts.blockSize)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], b[pos%r.opts.blockSize:])
+	}
+
+	return n, nil
+}
+
+// LoadContainer loads a SIF image from the resource at url, according to opts. Requests are made
+// using ctx. The returned FileImage is read-only.
+//
+// To specify options used to load the image, as described for sif.LoadContainerReader, consider
+// using OptReaderLoadOpts. When loading images from untrusted sources, consider bounding the
+// resources consumed using sif.OptLoadWithMaxDescriptors, sif.OptLoadWithMaxDescriptorsSize and
+// sif.OptLoadWithMaxObjectSize.
+//
+// On success, a FileImage is returned. The caller must call UnloadContainer to ensure resources
+// are released.
+func LoadContainer(ctx context.Context, url string, opts ...ReaderOpt) (*sif.FileImage, error) {
+	r, err := NewReaderAt(ctx, url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	f, err := sif.LoadContainerReader(r, r.Size(), r.opts.loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return f, nil
+}
+
+// IsURL reports whether s is an HTTP or HTTPS URL.
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
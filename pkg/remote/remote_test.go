@@ -0,0 +1,388 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apptainer/sif/v2/pkg/integrity"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+var corpus = filepath.Join("..", "..", "test", "images")
+
+// testServer is an HTTP server that serves content, and counts the requests it receives.
+type testServer struct {
+	*httptest.Server
+	requests atomic.Int64
+}
+
+// newTestServer returns a testServer that serves b. If ranges is false, range requests are not
+// supported.
+func newTestServer(t *testing.T, b []byte, ranges bool) *testServer {
+	t.Helper()
+
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+
+		if !ranges {
+			r.Header.Del("Range")
+		}
+
+		w.Header().Set("ETag", `"etag"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestNewReaderAt(t *testing.T) {
+	b := bytes.Repeat([]byte{0xfa, 0xce}, 64)
+
+	tests := []struct {
+		name     string
+		ranges   bool
+		notFound bool
+		opts     []ReaderOpt
+		wantSize int64
+		wantErr  error
+	}{
+		{
+			name:    "InvalidBlockSize",
+			ranges:  true,
+			opts:    []ReaderOpt{OptReaderBlockSize(0)},
+			wantErr: errInvalidBlockSize,
+		},
+		{
+			name:    "InvalidCacheBlocks",
+			ranges:  true,
+			opts:    []ReaderOpt{OptReaderCacheBlocks(0)},
+			wantErr: errInvalidCacheBlocks,
+		},
+		{
+			name:    "RangeNotSupported",
+			wantErr: errRangeNotSupported,
+		},
+		{
+			name:     "NotFound",
+			ranges:   true,
+			notFound: true,
+			wantErr:  &UnexpectedStatusError{StatusCode: http.StatusNotFound},
+		},
+		{
+			name:     "NoOpts",
+			ranges:   true,
+			wantSize: int64(len(b)),
+		},
+		{
+			name:     "SmallBlockSize",
+			ranges:   true,
+			opts:     []ReaderOpt{OptReaderBlockSize(16)},
+			wantSize: int64(len(b)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, b, tt.ranges)
+
+			if tt.notFound {
+				s.Config.Handler = http.NotFoundHandler()
+			}
+
+			r, err := NewReaderAt(context.Background(), s.URL, tt.opts...)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err == nil {
+				if got, want := r.Size(), tt.wantSize; got != want {
+					t.Errorf("got size %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderAt_ReadAt(t *testing.T) {
+	b := make([]byte, 100)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	tests := []struct {
+		name    string
+		off     int64
+		n       int
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "Start",
+			off:  0,
+			n:    10,
+			want: b[0:10],
+		},
+		{
+			name: "SpanBlocks",
+			off:  12,
+			n:    30,
+			want: b[12:42],
+		},
+		{
+			name: "End",
+			off:  90,
+			n:    10,
+			want: b[90:100],
+		},
+		{
+			name:    "PastEnd",
+			off:     95,
+			n:       10,
+			want:    b[95:100],
+			wantErr: io.EOF,
+		},
+		{
+			name:    "BeyondEnd",
+			off:     100,
+			n:       10,
+			want:    []byte{},
+			wantErr: io.EOF,
+		},
+		{
+			name:    "NegativeOffset",
+			off:     -1,
+			n:       10,
+			want:    []byte{},
+			wantErr: errNegativeOffset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, b, true)
+
+			r, err := NewReaderAt(context.Background(), s.URL, OptReaderBlockSize(16))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := make([]byte, tt.n)
+
+			n, err := r.ReadAt(p, tt.off)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if got, want := p[:n], tt.want; !bytes.Equal(got, want) {
+				t.Errorf("got data %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReaderAt_Cache(t *testing.T) {
+	b := make([]byte, 64)
+
+	s := newTestServer(t, b, true)
+
+	r, err := NewReaderAt(context.Background(), s.URL,
+		OptReaderBlockSize(16),
+		OptReaderCacheBlocks(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 1)
+
+	// Each offset is listed with the number of requests expected after reading from it. Block 0 is
+	// cached by NewReaderAt.
+	reads := []struct {
+		off          int64
+		wantRequests int64
+	}{
+		{off: 0, wantRequests: 1},
+		{off: 16, wantRequests: 2},
+		{off: 1, wantRequests: 2},
+		{off: 32, wantRequests: 3}, // Evicts block 1.
+		{off: 0, wantRequests: 3},
+		{off: 16, wantRequests: 4}, // Evicts block 2.
+		{off: 32, wantRequests: 5},
+	}
+
+	for _, rd := range reads {
+		if _, err := r.ReadAt(p, rd.off); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := s.requests.Load(), rd.wantRequests; got != want {
+			t.Errorf("offset %v: got %v requests, want %v", rd.off, got, want)
+		}
+	}
+}
+
+func TestReaderAt_Concurrent(t *testing.T) {
+	b := make([]byte, 64)
+
+	s := newTestServer(t, b, true)
+
+	r, err := NewReaderAt(context.Background(), s.URL, OptReaderBlockSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stall retrieval of subsequent blocks until released.
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	h := s.Config.Handler
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		h.ServeHTTP(w, req)
+	})
+
+	var wg sync.WaitGroup
+
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := r.ReadAt(make([]byte, 1), 16); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	<-started
+
+	// Reading a cached block must not wait for the stalled retrieval.
+	done := make(chan error)
+	go func() {
+		_, err := r.ReadAt(make([]byte, 1), 0)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("read of cached block blocked by retrieval")
+	}
+
+	close(release)
+	wg.Wait()
+
+	// Concurrent reads of the same block share a single retrieval.
+	if got, want := s.requests.Load(), int64(2); got != want {
+		t.Errorf("got %v requests, want %v", got, want)
+	}
+}
+
+func TestReaderAt_Modified(t *testing.T) {
+	b := make([]byte, 64)
+
+	s := newTestServer(t, b, true)
+
+	r, err := NewReaderAt(context.Background(), s.URL, OptReaderBlockSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve different content with a different entity tag.
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"modified"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	})
+
+	if _, err := r.ReadAt(make([]byte, 1), 16); !errors.Is(err, errResourceModified) {
+		t.Errorf("got error %v, want %v", err, errResourceModified)
+	}
+}
+
+func TestLoadContainer(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(corpus, "one-group-signed-dsse.sif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, b, true)
+
+	f, err := LoadContainer(context.Background(), s.URL, OptReaderBlockSize(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := f.UnloadContainer(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if got, want := f.DataOffset()+f.DataSize(), int64(len(b)); got != want {
+		t.Errorf("got image size %v, want %v", got, want)
+	}
+
+	sv, err := signature.LoadVerifierFromPEMFile(
+		filepath.Join("..", "..", "test", "keys", "ed25519-public.pem"), 0,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := integrity.NewVerifier(f, integrity.OptVerifyWithVerifier(sv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	if err := f.DeleteObject(1); !errors.Is(err, sif.ErrImageReadOnly) {
+		t.Errorf("got error %v, want %v", err, sif.ErrImageReadOnly)
+	}
+
+	// Load options are applied to the image.
+	want := &sif.LimitError{Limit: sif.LimitDescriptors}
+
+	_, err = LoadContainer(context.Background(), s.URL,
+		OptReaderLoadOpts(sif.OptLoadWithMaxDescriptors(1)),
+	)
+	if !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+
+	// Errors retrieving the image are wrapped.
+	u := newTestServer(t, b, false)
+
+	if _, err := LoadContainer(context.Background(), u.URL); !errors.Is(err, errRangeNotSupported) {
+		t.Errorf("got error %v, want %v", err, errRangeNotSupported)
+	}
+}
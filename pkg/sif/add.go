@@ -12,13 +12,22 @@
 package sif
 
 import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"time"
 )
 
 // addOpts accumulates object add options.
 type addOpts struct {
-	t time.Time
+	t                time.Time
+	descriptorGrowth int64
+	ctx              context.Context //nolint:containedctx
+	progress         ProgressFunc
 }
 
 // AddOpt are used to specify object add options.
@@ -41,12 +50,117 @@ func OptAddWithTime(t time.Time) AddOpt {
 	}
 }
 
+var errInvalidDescriptorGrowth = errors.New("descriptor growth must not be negative")
+
+// OptAddWithDescriptorGrowth specifies that, if the image does not have sufficient descriptor
+// capacity to add the data object, the capacity should be increased by n descriptors. If n is
+// zero, the capacity is not increased.
+func OptAddWithDescriptorGrowth(n int64) AddOpt {
+	return func(ao *addOpts) error {
+		if n < 0 {
+			return errInvalidDescriptorGrowth
+		}
+		ao.descriptorGrowth = n
+		return nil
+	}
+}
+
+// OptAddWithContext specifies that writing the data object should stop if ctx is done. If ctx is
+// done before the data object is written, the image is left unmodified, and the error from ctx is
+// returned.
+func OptAddWithContext(ctx context.Context) AddOpt {
+	return func(ao *addOpts) error {
+		ao.ctx = ctx
+		return nil
+	}
+}
+
+// OptAddWithProgress specifies that fn should be called to report progress as the data object is
+// written.
+func OptAddWithProgress(fn ProgressFunc) AddOpt {
+	return func(ao *addOpts) error {
+		ao.progress = fn
+		return nil
+	}
+}
+
+// growDescriptors increases the descriptor capacity of f by n. Data objects that would be
+// overwritten by the enlarged descriptor section are relocated to the end of the data section.
+func (f *FileImage) growDescriptors(n int64) error {
+	total := f.h.DescriptorsTotal + n
+	if total >= math.MaxUint32 {
+		return errDescriptorCapacityNotSupported
+	}
+
+	size := int64(binary.Size(rawDescriptor{})) * total
+
+	dataOffset := max(f.h.DataOffset, f.h.DescriptorsOffset+size)
+
+	// Identify data objects that overlap the enlarged descriptor section.
+	var rds []*rawDescriptor
+	for i := range f.rds {
+		if rd := &f.rds[i]; rd.Used && rd.Offset < dataOffset {
+			rds = append(rds, rd)
+		}
+	}
+
+	slices.SortFunc(rds, func(a, b *rawDescriptor) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	// Relocate overlapping data objects to the end of the data section, retaining alignment.
+	end := max(f.dataEnd(), dataOffset)
+
+	for _, rd := range rds {
+		offset, err := nextAligned(end, inferAlignment(rd.Offset))
+		if err != nil {
+			return err
+		}
+
+		if err := f.moveData(offset, rd.Offset, rd.Size); err != nil {
+			return err
+		}
+
+		rd.Offset = offset
+		rd.SizeWithPadding = offset - end + rd.Size
+		end = offset + rd.Size
+	}
+
+	f.rds = append(f.rds, make([]rawDescriptor, n)...)
+
+	f.h.DescriptorsFree += n
+	f.h.DescriptorsTotal = total
+	f.h.DescriptorsSize = size
+	f.h.DataOffset = dataOffset
+	f.h.DataSize = f.calculatedDataSize()
+
+	return nil
+}
+
 // AddObject adds a new data object and its descriptor into the specified SIF file.
 //
 // By default, the image modification time is set to the current time for non-deterministic images,
 // and unset otherwise. To override this, consider using OptAddDeterministic or OptAddWithTime.
+//
+// By default, an error is returned if the image does not have sufficient descriptor capacity to
+// add the data object. To enlarge the descriptor section as required, consider using
+// OptAddWithDescriptorGrowth. Enlarging the descriptor section may relocate existing data objects,
+// but does not invalidate existing signatures.
+//
+// To stop writing a large data object on request, consider using OptAddWithContext. To report
+// progress as the data object is written, consider using OptAddWithProgress. If writing the data
+// object fails, data already written is truncated, and the image is left unmodified.
 func (f *FileImage) AddObject(di DescriptorInput, opts ...AddOpt) error {
-	ao := addOpts{}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	ao := addOpts{
+		ctx: context.Background(),
+	}
 
 	if !f.isDeterministic() {
 		ao.t = time.Now()
@@ -67,17 +181,26 @@ func (f *FileImage) AddObject(di DescriptorInput, opts ...AddOpt) error {
 		i++
 	}
 
-	if err := f.writeDataObject(i, di, ao.t); err != nil {
+	s, err := f.saveState()
+	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	if err := f.writeDescriptors(); err != nil {
-		return fmt.Errorf("%w", err)
+	if i >= len(f.rds) && ao.descriptorGrowth > 0 {
+		if err := f.growDescriptors(ao.descriptorGrowth); err != nil {
+			return fmt.Errorf("%w", f.restoreState(s, err))
+		}
+	}
+
+	di.r = newProgress(ao.ctx, ao.progress, di.opts.size).reader(di.r)
+
+	if err := f.writeDataObject(i, di, ao.t); err != nil {
+		return fmt.Errorf("%w", f.restoreState(s, err))
 	}
 
 	f.h.ModifiedAt = ao.t.Unix()
 
-	if err := f.writeHeader(); err != nil {
+	if err := f.commit(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
@@ -10,7 +10,9 @@
 package sif
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"testing"
 	"time"
 
@@ -45,10 +47,77 @@ func TestAddObject(t *testing.T) {
 				),
 			},
 			di: getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
-				OptPartitionMetadata(FsSquash, PartPrimSys, "amd64"),
+				OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
 			),
 			wantErr: errPrimaryPartition,
 		},
+		{
+			name: "PrimaryPartitionMultiArch",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+				),
+			},
+			di: getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+				OptPartitionMetadata(FsSquash, PartPrimSys, "amd64"),
+			),
+		},
+		{
+			name: "ErrInvalidDescriptorGrowth",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(0),
+			},
+			di: getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+			opts: []AddOpt{
+				OptAddWithDescriptorGrowth(-1),
+			},
+			wantErr: errInvalidDescriptorGrowth,
+		},
+		{
+			name: "DescriptorGrowth",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(0),
+			},
+			di: getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+			opts: []AddOpt{
+				OptAddWithDescriptorGrowth(1),
+			},
+		},
+		{
+			name: "DescriptorGrowthRelocate",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(2),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+				),
+			},
+			di: getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+			opts: []AddOpt{
+				OptAddWithDescriptorGrowth(8),
+			},
+		},
+		{
+			name: "DescriptorGrowthNotRequired",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			},
+			di: getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+			opts: []AddOpt{
+				OptAddWithDescriptorGrowth(8),
+			},
+		},
 		{
 			name: "Deterministic",
 			createOpts: []CreateOpt{
@@ -157,3 +226,131 @@ func TestAddObject(t *testing.T) {
 		})
 	}
 }
+
+func TestAddObjectDescriptorGrowth(t *testing.T) {
+	var b Buffer
+
+	f, err := CreateContainer(&b,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptorCapacity(3),
+		OptCreateWithDescriptors(
+			getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+			getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}, OptObjectAlignment(64)),
+			getDescriptorInput(t, DataPartition, bytes.Repeat([]byte{0xff}, 8192),
+				OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+			),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record integrity-protected fields and data of existing objects.
+	type object struct {
+		integrity []byte
+		data      []byte
+	}
+
+	getObjects := func(f *FileImage) map[uint32]object {
+		objects := make(map[uint32]object)
+
+		f.WithDescriptors(func(d Descriptor) bool {
+			integrity, err := io.ReadAll(d.GetIntegrityReader())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := d.GetData()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			objects[d.ID()] = object{integrity, data}
+			return false
+		})
+
+		return objects
+	}
+
+	before := getObjects(f)
+
+	header, err := io.ReadAll(f.GetHeaderIntegrityReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.AddObject(getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+		OptAddWithDescriptorGrowth(1000),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := f.DescriptorsTotal(), int64(1003); got != want {
+		t.Errorf("got %v descriptors, want %v", got, want)
+	}
+
+	if got, want := f.DescriptorsFree(), int64(999); got != want {
+		t.Errorf("got %v free descriptors, want %v", got, want)
+	}
+
+	if got, want := f.DataOffset(), f.DescriptorsOffset()+f.DescriptorsSize(); got != want {
+		t.Errorf("got data offset %v, want %v", got, want)
+	}
+
+	if got, want := f.DataOffset()+f.DataSize(), b.Len(); got != want {
+		t.Errorf("got data end %v, want %v", got, want)
+	}
+
+	after := getObjects(f)
+
+	for id, want := range before {
+		got, ok := after[id]
+		if !ok {
+			t.Fatalf("object %v not found", id)
+		}
+
+		if !bytes.Equal(got.integrity, want.integrity) {
+			t.Errorf("object %v: integrity-protected fields changed", id)
+		}
+
+		if !bytes.Equal(got.data, want.data) {
+			t.Errorf("object %v: data changed", id)
+		}
+	}
+
+	f.WithDescriptors(func(d Descriptor) bool {
+		if d.Offset() < f.DataOffset() {
+			t.Errorf("object %v: offset %v precedes data section", d.ID(), d.Offset())
+		}
+		return false
+	})
+
+	d, err := f.GetDescriptor(WithID(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Offset()%64 != 0 {
+		t.Errorf("object 2: offset %v not aligned", d.Offset())
+	}
+
+	if got, err := io.ReadAll(f.GetHeaderIntegrityReader()); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, header) {
+		t.Error("header integrity-protected fields changed")
+	}
+
+	// Reload the image, and ensure it is consistent.
+	g, err := LoadContainer(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(g.rds), 1003; got != want {
+		t.Errorf("got %v descriptors, want %v", got, want)
+	}
+
+	if got := getObjects(g); len(got) != 4 {
+		t.Errorf("got %v objects, want 4", len(got))
+	}
+}
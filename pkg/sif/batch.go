@@ -0,0 +1,282 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// batchOpts accumulates batch options.
+type batchOpts struct {
+	t                time.Time
+	descriptorGrowth int64
+}
+
+// BatchOpt are used to specify batch options.
+type BatchOpt func(*batchOpts) error
+
+// OptBatchDeterministic sets header/descriptor fields to values that support deterministic
+// modification of images.
+func OptBatchDeterministic() BatchOpt {
+	return func(bo *batchOpts) error {
+		bo.t = time.Time{}
+		return nil
+	}
+}
+
+// OptBatchWithTime specifies t as the image/object modification time.
+func OptBatchWithTime(t time.Time) BatchOpt {
+	return func(bo *batchOpts) error {
+		bo.t = t
+		return nil
+	}
+}
+
+// OptBatchWithDescriptorGrowth specifies that, if the image does not have sufficient descriptor
+// capacity to add a data object, the capacity should be increased by n descriptors. If n is zero,
+// the capacity is not increased.
+func OptBatchWithDescriptorGrowth(n int64) BatchOpt {
+	return func(bo *batchOpts) error {
+		if n < 0 {
+			return errInvalidDescriptorGrowth
+		}
+		bo.descriptorGrowth = n
+		return nil
+	}
+}
+
+var errTxDone = errors.New("transaction has already completed")
+
+// Tx stages modifications to a FileImage. Staged modifications are written to the image by
+// FileImage.Batch.
+type Tx struct {
+	f      *FileImage
+	bo     batchOpts
+	done   bool  // Batch has returned.
+	staged bool  // At least one modification has been staged.
+	err    error // First error encountered, if any.
+}
+
+// do calls fn to stage a modification to the image. If a previous modification failed, the error
+// from that modification is returned, and fn is not called.
+func (tx *Tx) do(fn func(f *FileImage) error) error {
+	if tx.done {
+		return fmt.Errorf("%w", errTxDone)
+	}
+
+	if tx.err == nil {
+		tx.err = fn(tx.f)
+		tx.staged = true
+	}
+
+	return tx.err
+}
+
+// AddObject stages the addition of a new data object described by di. See FileImage.AddObject.
+func (tx *Tx) AddObject(di DescriptorInput) error {
+	return tx.do(func(f *FileImage) error {
+		return f.AddObject(di,
+			OptAddWithTime(tx.bo.t),
+			OptAddWithDescriptorGrowth(tx.bo.descriptorGrowth),
+		)
+	})
+}
+
+// DeleteObject stages the deletion of the data object with id. See FileImage.DeleteObject.
+func (tx *Tx) DeleteObject(id uint32) error {
+	return tx.DeleteObjects(WithID(id))
+}
+
+// DeleteObjects stages the deletion of the data objects selected by fn. See
+// FileImage.DeleteObjects.
+func (tx *Tx) DeleteObjects(fn DescriptorSelectorFunc) error {
+	return tx.do(func(f *FileImage) error {
+		return f.DeleteObjects(fn, OptDeleteWithTime(tx.bo.t))
+	})
+}
+
+// SetPrimPart stages setting the system partition with id to be the primary one. See
+// FileImage.SetPrimPart.
+func (tx *Tx) SetPrimPart(id uint32) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetPrimPart(id, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetMetadata stages setting the metadata of the data object with id to md. See
+// FileImage.SetMetadata.
+func (tx *Tx) SetMetadata(id uint32, md encoding.BinaryMarshaler) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetMetadata(id, md, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetOCIBlobDigest stages setting the digest of the OCI blob object with id to h. See
+// FileImage.SetOCIBlobDigest.
+func (tx *Tx) SetOCIBlobDigest(id uint32, h v1.Hash) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetOCIBlobDigest(id, h, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetName stages setting the name of the data object with id to name. See FileImage.SetName.
+func (tx *Tx) SetName(id uint32, name string) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetName(id, name, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetGroupID stages setting the group ID of the data object with id to groupID. See
+// FileImage.SetGroupID.
+func (tx *Tx) SetGroupID(id, groupID uint32) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetGroupID(id, groupID, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetNoGroup stages removing the data object with id from its group. See FileImage.SetNoGroup.
+func (tx *Tx) SetNoGroup(id uint32) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetNoGroup(id, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetLinkedID stages linking the data object with id to the data object with linkedID. See
+// FileImage.SetLinkedID.
+func (tx *Tx) SetLinkedID(id, linkedID uint32) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetLinkedID(id, linkedID, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetLinkedGroupID stages linking the data object with id to the group with groupID. See
+// FileImage.SetLinkedGroupID.
+func (tx *Tx) SetLinkedGroupID(id, groupID uint32) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetLinkedGroupID(id, groupID, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetNoLink stages removing the link of the data object with id. See FileImage.SetNoLink.
+func (tx *Tx) SetNoLink(id uint32) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetNoLink(id, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetLaunchScript stages setting the launch script of the image to s. See
+// FileImage.SetLaunchScript.
+func (tx *Tx) SetLaunchScript(s string) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetLaunchScript(s, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// SetID stages setting the ID of the image to id. See FileImage.SetID.
+func (tx *Tx) SetID(id string) error {
+	return tx.do(func(f *FileImage) error {
+		return f.SetID(id, OptSetWithTime(tx.bo.t))
+	})
+}
+
+// Batch calls fn to stage modifications to f, according to opts. If fn returns nil and all staged
+// modifications succeed, the descriptors and global header are written once, and the image
+// modification time is updated once. Otherwise, the modifications are rolled back, data written
+// to the image by fn is truncated, and the error is returned. If no modifications are staged, the
+// image is not modified.
+//
+// Data written by Tx.AddObject is appended to the image as it is staged, and never overwrites
+// existing data. Data objects deleted by Tx.DeleteObject and Tx.DeleteObjects are not zeroed, and
+// the image is not compacted. Consider
+// using Repack to reclaim unused space. f is locked while fn is called, so fn must not call methods
+// of f.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptBatchDeterministic or OptBatchWithTime.
+//
+// By default, an error is returned if the image does not have sufficient descriptor capacity to
+// add a data object. To enlarge the descriptor section as required, consider using
+// OptBatchWithDescriptorGrowth.
+func (f *FileImage) Batch(fn func(*Tx) error, opts ...BatchOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	bo := batchOpts{}
+
+	if !f.isDeterministic() {
+		bo.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&bo); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	size, err := f.size()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// Stage modifications to a copy of f, so that the state of f can be retained if a modification
+	// fails.
+	staging := &FileImage{
+		rw:            f.rw,
+		h:             f.h,
+		rds:           slices.Clone(f.rds),
+		journaled:     f.journaled,
+		inBatch:       true,
+		batchSize:     size,
+		minIDs:        maps.Clone(f.minIDs),
+		maxObjectSize: f.maxObjectSize,
+	}
+
+	tx := &Tx{f: staging, bo: bo}
+
+	err = fn(tx)
+
+	tx.done = true
+
+	if err == nil {
+		err = tx.err
+	}
+
+	if err != nil {
+		if terr := f.rw.Truncate(size); terr != nil {
+			return fmt.Errorf("%w", errors.Join(err, terr))
+		}
+		return fmt.Errorf("%w", err)
+	}
+
+	if !tx.staged {
+		return nil
+	}
+
+	f.h, f.rds, f.minIDs = staging.h, staging.rds, staging.minIDs
+
+	f.h.ModifiedAt = bo.t.Unix()
+
+	if err := f.commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+)
+
+func TestFileImage_Batch(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name       string
+		createOpts []CreateOpt
+		fn         func(t *testing.T, tx *Tx) error
+		opts       []BatchOpt
+		wantErr    error
+	}{
+		{
+			name: "Empty",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			fn: func(*testing.T, *Tx) error { return nil },
+			opts: []BatchOpt{
+				OptBatchWithTime(time.Unix(946702800, 0)),
+			},
+		},
+		{
+			name: "AddObjects",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				for _, b := range [][]byte{{0xfa, 0xce}, {0xfe, 0xed}, {0xde, 0xad}} {
+					if err := tx.AddObject(getDescriptorInput(t, DataGeneric, b)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "AddObjectsDescriptorGrowth",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(1),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				for _, b := range [][]byte{{0xfa, 0xce}, {0xfe, 0xed}, {0xde, 0xad}} {
+					if err := tx.AddObject(getDescriptorInput(t, DataGeneric, b)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			opts: []BatchOpt{
+				OptBatchWithDescriptorGrowth(1),
+			},
+		},
+		{
+			name: "Mixed",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+						OptPartitionMetadata(FsSquash, PartSystem, "386"),
+					),
+				),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				if err := tx.DeleteObject(1); err != nil {
+					return err
+				}
+
+				if err := tx.AddObject(getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad})); err != nil {
+					return err
+				}
+
+				if err := tx.SetName(1, "name"); err != nil {
+					return err
+				}
+
+				if err := tx.SetLinkedID(1, 2); err != nil {
+					return err
+				}
+
+				if err := tx.SetPrimPart(2); err != nil {
+					return err
+				}
+
+				return tx.SetLaunchScript("#!/bin/sh\n")
+			},
+			opts: []BatchOpt{
+				OptBatchWithTime(time.Unix(946702800, 0)),
+			},
+		},
+		{
+			name: "ErrInsufficientCapacity",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(1),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				for _, b := range [][]byte{{0xfa, 0xce}, {0xfe, 0xed}} {
+					if err := tx.AddObject(getDescriptorInput(t, DataGeneric, b)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantErr: errInsufficientCapacity,
+		},
+		{
+			name: "ErrIgnored",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				if err := tx.AddObject(getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed})); err != nil {
+					return err
+				}
+
+				// An error from a staged modification causes the batch to fail, even if ignored.
+				_ = tx.DeleteObject(3)
+
+				return tx.SetName(1, "name")
+			},
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name: "ErrFunc",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				if err := tx.AddObject(getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed})); err != nil {
+					return err
+				}
+
+				if err := tx.DeleteObject(1); err != nil {
+					return err
+				}
+
+				return errFailed
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "ErrFuncDeleteLast",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+				),
+			},
+			fn: func(t *testing.T, tx *Tx) error {
+				// The space occupied by the deleted data object must not be reused by the batch.
+				if err := tx.DeleteObject(2); err != nil {
+					return err
+				}
+
+				if err := tx.AddObject(getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad})); err != nil {
+					return err
+				}
+
+				return errFailed
+			},
+			wantErr: errFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b, tt.createOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := bytes.Clone(b.Bytes())
+
+			err = f.Batch(func(tx *Tx) error { return tt.fn(t, tx) }, tt.opts...)
+
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err != nil {
+				// The image must be unmodified, and the in-memory state restored.
+				if !bytes.Equal(b.Bytes(), before) {
+					t.Error("image modified by failed batch")
+				}
+
+				if err := f.writeDescriptors(); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.writeHeader(); err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(b.Bytes(), before) {
+					t.Error("in-memory state not restored by failed batch")
+				}
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			if err == nil {
+				g := goldie.New(t, goldie.WithTestNameForDir(true))
+				g.Assert(t, tt.name, b.Bytes())
+			}
+		})
+	}
+}
+
+func TestFileImage_BatchTxDone(t *testing.T) {
+	var b Buffer
+
+	f, err := CreateContainer(&b, OptCreateDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tx *Tx
+
+	if err := f.Batch(func(t *Tx) error {
+		tx = t
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tx.SetName(1, "name"), errTxDone; !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -12,11 +12,14 @@ package sif
 import (
 	"errors"
 	"io"
+	"sync"
 )
 
 // A Buffer is a variable-sized buffer of bytes that implements the sif.ReadWriter interface. The
-// zero value for Buffer is an empty buffer ready to use.
+// zero value for Buffer is an empty buffer ready to use. A Buffer is safe for concurrent use by
+// multiple goroutines.
 type Buffer struct {
+	mu  sync.RWMutex
 	buf []byte
 	pos int64
 }
@@ -30,6 +33,9 @@ var errNegativeOffset = errors.New("negative offset")
 
 // ReadAt implements the io.ReaderAt interface.
 func (b *Buffer) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
 	if off < 0 {
 		return 0, errNegativeOffset
 	}
@@ -49,6 +55,9 @@ var errNegativePosition = errors.New("negative position")
 
 // Write implements the io.Writer interface.
 func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	if b.pos < 0 {
 		return 0, errNegativePosition
 	}
@@ -66,6 +75,9 @@ var errInvalidWhence = errors.New("invalid whence")
 
 // Seek implements the io.Seeker interface.
 func (b *Buffer) Seek(offset int64, whence int) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	var abs int64
 
 	switch whence {
@@ -91,6 +103,9 @@ var errTruncateRange = errors.New("truncation out of range")
 
 // Truncate discards all but the first n bytes from the buffer.
 func (b *Buffer) Truncate(n int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	if n < 0 || n > int64(len(b.buf)) {
 		return errTruncateRange
 	}
@@ -101,7 +116,17 @@ func (b *Buffer) Truncate(n int64) error {
 
 // Bytes returns the contents of the buffer. The slice is valid for use only until the next buffer
 // modification (that is, only until the next call to a method like ReadAt, Write, or Truncate).
-func (b *Buffer) Bytes() []byte { return b.buf }
+func (b *Buffer) Bytes() []byte {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.buf
+}
 
 // Len returns the number of bytes in the buffer.
-func (b *Buffer) Len() int64 { return int64(len(b.buf)) }
+func (b *Buffer) Len() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return int64(len(b.buf))
+}
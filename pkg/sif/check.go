@@ -0,0 +1,216 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Severity describes the severity of a Finding.
+type Severity uint8
+
+// List of supported finding severities.
+const (
+	SeverityWarning Severity = iota + 1 // Inconsistency that does not prevent use of the image
+	SeverityError                       // Inconsistency that may result in incorrect behavior
+)
+
+// String returns a human-readable representation of s.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Finding describes an inconsistency found while checking an image.
+type Finding struct {
+	Severity Severity // Severity of the inconsistency.
+	ID       uint32   // ID of the data object concerned, or zero if the image as a whole is concerned.
+	Message  string   // Human-readable description of the inconsistency.
+}
+
+// String returns a human-readable representation of f.
+func (f Finding) String() string {
+	if f.ID == 0 {
+		return fmt.Sprintf("%v: %v", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%v: object %v: %v", f.Severity, f.ID, f.Message)
+}
+
+// checker accumulates findings.
+type checker struct {
+	fs []Finding
+}
+
+// errorf records a finding of severity SeverityError concerning the data object with the
+// specified id.
+func (c *checker) errorf(id uint32, format string, a ...any) {
+	c.fs = append(c.fs, Finding{SeverityError, id, fmt.Sprintf(format, a...)})
+}
+
+// warnf records a finding of severity SeverityWarning concerning the data object with the
+// specified id.
+func (c *checker) warnf(id uint32, format string, a ...any) {
+	c.fs = append(c.fs, Finding{SeverityWarning, id, fmt.Sprintf(format, a...)})
+}
+
+// size returns the size of the image backing f.
+func (f *FileImage) size() (int64, error) {
+	return f.rw.Seek(0, io.SeekEnd)
+}
+
+// checkHeader checks the global header of f against the descriptors of f.
+func (f *FileImage) checkHeader(c *checker, size int64) {
+	if f.h.DescriptorsOffset < int64(binary.Size(f.h)) {
+		c.errorf(0, "descriptor section overlaps global header")
+	}
+
+	if f.h.DescriptorsOffset+f.h.DescriptorsSize > f.h.DataOffset {
+		c.errorf(0, "descriptor section overlaps data section")
+	}
+
+	var free int64
+	for _, rd := range f.rds {
+		if !rd.Used {
+			free++
+		}
+	}
+
+	if free != f.h.DescriptorsFree {
+		c.errorf(0, "descriptors free is %v, but %v descriptors are unused", f.h.DescriptorsFree, free)
+	}
+
+	if want := f.calculatedDataSize(); f.h.DataSize != want {
+		c.errorf(0, "data size is %v, but data objects occupy %v bytes", f.h.DataSize, want)
+	}
+
+	if end := f.h.DataOffset + f.h.DataSize; end > size {
+		c.errorf(0, "data section extends past end of image (%v > %v)", end, size)
+	}
+
+	if want := f.primaryArch(); f.h.Arch != want {
+		c.warnf(0, "primary architecture is %v, but first primary system partition is %v",
+			f.h.Arch.GoArch(), want.GoArch(),
+		)
+	}
+}
+
+// checkDescriptors checks the descriptors of f.
+func (f *FileImage) checkDescriptors(c *checker, size int64) {
+	ids := make(map[uint32]bool)
+	groupIDs := make(map[uint32]bool)
+
+	for _, rd := range f.rds {
+		if rd.Used {
+			ids[rd.ID] = true
+			groupIDs[rd.GroupID&^descrGroupMask] = true
+		}
+	}
+
+	seen := make(map[uint32]bool)
+	primaryArchs := make(map[archType]bool)
+
+	for i, rd := range f.rds {
+		if !rd.Used {
+			continue
+		}
+
+		if rd.ID == 0 {
+			c.errorf(0, "data object at descriptor index %v has invalid ID", i)
+		} else if seen[rd.ID] {
+			c.errorf(rd.ID, "duplicate ID")
+		}
+		seen[rd.ID] = true
+
+		if rd.Offset < f.h.DataOffset || rd.Size < 0 {
+			c.errorf(rd.ID, "data object outside data section (offset %v, size %v)", rd.Offset, rd.Size)
+		} else if end := rd.Offset + rd.Size; end > size {
+			c.errorf(rd.ID, "data object extends past end of image (%v > %v)", end, size)
+		}
+
+		if rd.LinkedID&descrGroupMask == descrGroupMask {
+			if groupID := rd.LinkedID &^ descrGroupMask; !groupIDs[groupID] {
+				c.warnf(rd.ID, "linked to group %v, which does not exist", groupID)
+			}
+		} else if rd.LinkedID != 0 && !ids[rd.LinkedID] {
+			c.warnf(rd.ID, "linked to object %v, which does not exist", rd.LinkedID)
+		}
+
+		if rd.isPartitionOfType(PartPrimSys) {
+			var p partition
+			if err := rd.getExtra(binaryUnmarshaler{&p}); err != nil {
+				c.errorf(rd.ID, "invalid partition metadata: %v", err)
+			} else if primaryArchs[p.Arch] {
+				c.errorf(rd.ID, "multiple primary system partitions for architecture %v", p.Arch.GoArch())
+			}
+			primaryArchs[p.Arch] = true
+		}
+	}
+}
+
+// checkOverlap checks that the data objects of f do not overlap.
+func (f *FileImage) checkOverlap(c *checker) {
+	var rds []rawDescriptor
+	for _, rd := range f.rds {
+		if rd.Used && rd.Size > 0 {
+			rds = append(rds, rd)
+		}
+	}
+
+	slices.SortStableFunc(rds, func(a, b rawDescriptor) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	// Track the data object that extends furthest, as it may overlap more than one subsequent
+	// data object.
+	var last rawDescriptor
+	for i, rd := range rds {
+		if i > 0 && rd.Offset < last.Offset+last.Size {
+			c.errorf(rd.ID, "data object overlaps object %v", last.ID)
+		}
+
+		if i == 0 || rd.Offset+rd.Size > last.Offset+last.Size {
+			last = rd
+		}
+	}
+}
+
+// Check checks the structural consistency of f, and returns any inconsistencies found. If no
+// inconsistencies are found, the returned slice is empty.
+//
+// Check verifies that the global header is consistent with the descriptors, that data objects
+// have unique IDs, lie within the image and do not overlap, that links refer to data objects or
+// groups that exist, and that there is at most one primary system partition per architecture.
+// Integrity of the data object contents is not checked. To verify signatures, consider using the
+// integrity package.
+func (f *FileImage) Check() ([]Finding, error) {
+	// Determining the image size seeks the backing storage, so exclude other callers.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	size, err := f.size()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var c checker
+	f.checkHeader(&c, size)
+	f.checkDescriptors(&c, size)
+	f.checkOverlap(&c)
+
+	return c.fs, nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileImage_Check(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(f *FileImage)
+		want   []Finding
+	}{
+		{
+			name:   "OK",
+			modify: func(*FileImage) {},
+		},
+		{
+			name: "DescriptorsFree",
+			modify: func(f *FileImage) {
+				f.h.DescriptorsFree--
+			},
+			want: []Finding{
+				{SeverityError, 0, "descriptors free is 44, but 45 descriptors are unused"},
+			},
+		},
+		{
+			name: "DataSize",
+			modify: func(f *FileImage) {
+				f.h.DataSize++
+			},
+			want: []Finding{
+				{SeverityError, 0, "data size is 4697, but data objects occupy 4696 bytes"},
+				{SeverityError, 0, "data section extends past end of image (36873 > 36872)"},
+			},
+		},
+		{
+			name: "DescriptorsOverlapData",
+			modify: func(f *FileImage) {
+				f.h.DescriptorsSize = f.h.DataOffset
+			},
+			want: []Finding{
+				{SeverityError, 0, "descriptor section overlaps data section"},
+			},
+		},
+		{
+			name: "PrimaryArch",
+			modify: func(f *FileImage) {
+				f.h.Arch = getSIFArch("amd64")
+			},
+			want: []Finding{
+				{SeverityWarning, 0, "primary architecture is amd64, but first primary system partition is 386"},
+			},
+		},
+		{
+			name: "DuplicateID",
+			modify: func(f *FileImage) {
+				f.rds[2].ID = 2
+			},
+			want: []Finding{
+				{SeverityError, 2, "duplicate ID"},
+			},
+		},
+		{
+			name: "InvalidID",
+			modify: func(f *FileImage) {
+				f.rds[2].ID = 0
+			},
+			want: []Finding{
+				{SeverityError, 0, "data object at descriptor index 2 has invalid ID"},
+			},
+		},
+		{
+			name: "OutsideDataSection",
+			modify: func(f *FileImage) {
+				f.rds[0].Offset = f.h.DescriptorsOffset
+			},
+			want: []Finding{
+				{SeverityError, 1, "data object outside data section (offset 4096, size 4)"},
+			},
+		},
+		{
+			name: "PastEOF",
+			modify: func(f *FileImage) {
+				f.rds[2].Size = 8
+			},
+			want: []Finding{
+				{SeverityError, 0, "data size is 4696, but data objects occupy 4700 bytes"},
+				{SeverityError, 3, "data object extends past end of image (36876 > 36872)"},
+			},
+		},
+		{
+			name: "Overlap",
+			modify: func(f *FileImage) {
+				f.rds[0].Size = 4098
+			},
+			want: []Finding{
+				{SeverityError, 2, "data object overlaps object 1"},
+			},
+		},
+		{
+			name: "OverlapMultiple",
+			modify: func(f *FileImage) {
+				f.rds[0].Size = 4101
+			},
+			want: []Finding{
+				{SeverityError, 2, "data object overlaps object 1"},
+				{SeverityError, 3, "data object overlaps object 1"},
+			},
+		},
+		{
+			name: "DanglingLink",
+			modify: func(f *FileImage) {
+				f.rds[2].LinkedID = 7
+			},
+			want: []Finding{
+				{SeverityWarning, 3, "linked to object 7, which does not exist"},
+			},
+		},
+		{
+			name: "DanglingGroupLink",
+			modify: func(f *FileImage) {
+				f.rds[2].LinkedID = 7 | descrGroupMask
+			},
+			want: []Finding{
+				{SeverityWarning, 3, "linked to group 7, which does not exist"},
+			},
+		},
+		{
+			name: "MultiplePrimaryPartitions",
+			modify: func(f *FileImage) {
+				f.rds[2].DataType = DataPartition
+				f.rds[2].Extra = f.rds[1].Extra
+			},
+			want: []Finding{
+				{SeverityError, 3, "multiple primary system partitions for architecture 386"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce, 0xfe, 0xed},
+						OptPartitionMetadata(FsRaw, PartSystem, "386"),
+					),
+					getDescriptorInput(t, DataPartition, []byte{0xde, 0xad, 0xbe, 0xef},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+					getDescriptorInput(t, DataGeneric, []byte{0xba, 0xdd, 0xca, 0xfe},
+						OptLinkedID(1),
+					),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.modify(f)
+
+			got, err := f.Check()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got findings %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileImage_CheckCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(corpus, "*.sif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			f, err := LoadContainerFromPath(path, OptLoadWithFlag(os.O_RDONLY))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+
+			got, err := f.Check()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) > 0 {
+				t.Errorf("got findings %v, want none", got)
+			}
+		})
+	}
+}
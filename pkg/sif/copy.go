@@ -0,0 +1,338 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// copyOpts accumulates object copy options.
+type copyOpts struct {
+	t                time.Time
+	descriptorGrowth int64
+	ctx              context.Context //nolint:containedctx
+	progress         ProgressFunc
+}
+
+// CopyOpt are used to specify object copy options.
+type CopyOpt func(*copyOpts) error
+
+// OptCopyDeterministic sets header/descriptor fields to values that support deterministic
+// modification of images.
+func OptCopyDeterministic() CopyOpt {
+	return func(co *copyOpts) error {
+		co.t = time.Time{}
+		return nil
+	}
+}
+
+// OptCopyWithTime specifies t as the image/object modification time.
+func OptCopyWithTime(t time.Time) CopyOpt {
+	return func(co *copyOpts) error {
+		co.t = t
+		return nil
+	}
+}
+
+// OptCopyWithDescriptorGrowth specifies that, if the image does not have sufficient descriptor
+// capacity to copy the data objects, the capacity should be increased in increments of n
+// descriptors. If n is zero, the capacity is not increased.
+func OptCopyWithDescriptorGrowth(n int64) CopyOpt {
+	return func(co *copyOpts) error {
+		if n < 0 {
+			return errInvalidDescriptorGrowth
+		}
+		co.descriptorGrowth = n
+		return nil
+	}
+}
+
+// OptCopyWithContext specifies that copying data objects should stop if ctx is done. If ctx is done
+// before all data objects are copied, the image is left unmodified, and the error from ctx is
+// returned.
+func OptCopyWithContext(ctx context.Context) CopyOpt {
+	return func(co *copyOpts) error {
+		co.ctx = ctx
+		return nil
+	}
+}
+
+// OptCopyWithProgress specifies that fn should be called to report progress as data objects are
+// copied.
+func OptCopyWithProgress(fn ProgressFunc) CopyOpt {
+	return func(co *copyOpts) error {
+		co.progress = fn
+		return nil
+	}
+}
+
+var errUnresolvedLink = errors.New("linked data object not selected for copy")
+
+// nextGroupID returns a group ID that is greater than any group ID in use in f.
+func (f *FileImage) nextGroupID() uint32 {
+	var groupID uint32
+	for _, rd := range f.rds {
+		if rd.Used {
+			groupID = max(groupID, rd.GroupID&^descrGroupMask)
+		}
+	}
+	return groupID + 1
+}
+
+// freeDescriptors returns the indices of the unused descriptors in f.
+func (f *FileImage) freeDescriptors() []int {
+	var is []int
+	for i, rd := range f.rds {
+		if !rd.Used {
+			is = append(is, i)
+		}
+	}
+	return is
+}
+
+// copyInputOpts returns options that describe a copy of the data object described by rd. The
+// group and link of the data object are remapped according to ids and groupIDs.
+func copyInputOpts(rd rawDescriptor, ids, groupIDs map[uint32]uint32) ([]DescriptorInputOpt, error) {
+	opts := []DescriptorInputOpt{
+		OptObjectName(strings.TrimRight(string(rd.Name[:]), "\000")),
+		OptObjectAlignment(inferAlignment(rd.Offset)),
+		OptObjectSize(rd.Size),
+		OptNoGroup(),
+	}
+
+	if groupID := rd.GroupID &^ descrGroupMask; groupID != 0 {
+		opts = append(opts, OptGroupID(groupIDs[groupID]))
+	}
+
+	if rd.LinkedID&descrGroupMask == descrGroupMask {
+		groupID, ok := groupIDs[rd.LinkedID&^descrGroupMask]
+		if !ok {
+			return nil, fmt.Errorf("object %v: %w", rd.ID, errUnresolvedLink)
+		}
+		opts = append(opts, OptLinkedGroupID(groupID))
+	} else if rd.LinkedID != 0 {
+		id, ok := ids[rd.LinkedID]
+		if !ok {
+			return nil, fmt.Errorf("object %v: %w", rd.ID, errUnresolvedLink)
+		}
+		opts = append(opts, OptLinkedID(id))
+	}
+
+	// Partition metadata is decoded, so that primary system partitions are handled consistently
+	// with newly added data objects. Other metadata is copied verbatim.
+	if rd.DataType == DataPartition {
+		var p partition
+		if err := rd.getExtra(binaryUnmarshaler{&p}); err != nil {
+			return nil, err
+		}
+		opts = append(opts, OptMetadata(p))
+	} else {
+		opts = append(opts, OptMetadata(binaryMarshaler{rd.Extra}))
+	}
+
+	return opts, nil
+}
+
+// copyRaw returns copies of the raw descriptors selected by fn.
+func (f *FileImage) copyRaw(fn DescriptorSelectorFunc) ([]rawDescriptor, error) {
+	var rds []rawDescriptor
+	err := f.withDescriptors(fn, func(rd *rawDescriptor) error {
+		rds = append(rds, *rd)
+		return nil
+	})
+	return rds, err
+}
+
+// CopyObjects copies the data objects in src selected by fn to f, according to opts. On success,
+// the descriptors of the copied data objects are returned. If fn does not select any data
+// objects, an error wrapping ErrObjectNotFound is returned.
+//
+// The data, name and metadata of each data object are preserved. Data objects are assigned IDs in
+// f, and each data object group in src that contains a selected data object is assigned a new
+// group ID in f. Links between data objects are remapped accordingly. If a selected data object is
+// linked to a data object or group that is not selected, an error is returned.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptCopyDeterministic or OptCopyWithTime.
+//
+// By default, an error is returned if the image does not have sufficient descriptor capacity to
+// copy the data objects. To enlarge the descriptor section as required, consider using
+// OptCopyWithDescriptorGrowth.
+//
+// To stop copying large data objects on request, consider using OptCopyWithContext. To report
+// progress as data objects are copied, consider using OptCopyWithProgress. If copying a data object
+// fails, data already written is truncated, and the image is left unmodified.
+func (f *FileImage) CopyObjects(src *FileImage, fn DescriptorSelectorFunc, opts ...CopyOpt) ([]Descriptor, error) {
+	// When copying from another image, select the data objects before locking f, so that the two
+	// images are never locked at the same time.
+	var rds []rawDescriptor
+	if src != f {
+		var err error
+
+		src.mu.RLock()
+		rds, err = src.copyRaw(fn)
+		src.mu.RUnlock()
+
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	co := copyOpts{
+		ctx: context.Background(),
+	}
+
+	if !f.isDeterministic() {
+		co.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&co); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if src == f {
+		var err error
+		if rds, err = f.copyRaw(fn); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if len(rds) == 0 {
+		return nil, fmt.Errorf("%w", ErrObjectNotFound)
+	}
+
+	// Determine the descriptors to be used, growing the descriptor section if necessary.
+	is := f.freeDescriptors()
+
+	var growth int64
+	if need := int64(len(rds) - len(is)); need > 0 {
+		if co.descriptorGrowth == 0 {
+			return nil, fmt.Errorf("%w", errInsufficientCapacity)
+		}
+
+		growth = (need + co.descriptorGrowth - 1) / co.descriptorGrowth * co.descriptorGrowth
+
+		for i := range int(growth) {
+			is = append(is, len(f.rds)+i)
+		}
+	}
+
+	// Assign IDs in f to the data objects and groups being copied.
+	ids := make(map[uint32]uint32)
+	groupIDs := make(map[uint32]uint32)
+	nextGroupID := f.nextGroupID()
+	primaryArchs := make(map[archType]bool)
+
+	for i, rd := range rds {
+		ids[rd.ID] = uint32(is[i]) + 1 //nolint:gosec // Overflow checked when descriptor allocated.
+
+		if groupID := rd.GroupID &^ descrGroupMask; groupID != 0 {
+			if _, ok := groupIDs[groupID]; !ok {
+				groupIDs[groupID] = nextGroupID
+				nextGroupID++
+			}
+		}
+
+		// Ensure at most one primary system partition exists per architecture.
+		if rd.isPartitionOfType(PartPrimSys) {
+			var p partition
+			if err := rd.getExtra(binaryUnmarshaler{&p}); err != nil {
+				return nil, fmt.Errorf("%w", err)
+			}
+
+			if primaryArchs[p.Arch] {
+				return nil, fmt.Errorf("%w: %v", errPrimaryPartition, p.Arch.GoArch())
+			}
+			primaryArchs[p.Arch] = true
+
+			if err := f.checkPrimaryPartition(p.Arch); err != nil {
+				return nil, fmt.Errorf("%w", err)
+			}
+		}
+	}
+
+	// Validate all data objects prior to modifying the image, so that it is not left partially
+	// modified.
+	dopts := make([][]DescriptorInputOpt, 0, len(rds))
+	for _, rd := range rds {
+		opts, err := copyInputOpts(rd, ids, groupIDs)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		dopts = append(dopts, opts)
+	}
+
+	s, err := f.saveState()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if growth > 0 {
+		if err := f.growDescriptors(growth); err != nil {
+			return nil, fmt.Errorf("%w", f.restoreState(s, err))
+		}
+	}
+
+	var total int64
+	for _, rd := range rds {
+		total += rd.Size
+	}
+
+	p := newProgress(co.ctx, co.progress, total)
+
+	ds := make([]Descriptor, 0, len(rds))
+	for i, rd := range rds {
+		// Growing the descriptor section may relocate data objects when copying within an image, so
+		// retrieve the current descriptor.
+		if src == f {
+			r, err := f.getDescriptor(WithID(rd.ID))
+			if err != nil {
+				return nil, fmt.Errorf("%w", f.restoreState(s, err))
+			}
+			rd = *r
+		}
+
+		r := io.NewSectionReader(src.rw, rd.Offset, rd.Size)
+
+		di, err := NewDescriptorInput(rd.DataType, r, dopts[i]...)
+		if err != nil {
+			return nil, fmt.Errorf("%w", f.restoreState(s, err))
+		}
+		di.r = p.reader(di.r)
+
+		if err := f.writeDataObject(is[i], di, co.t); err != nil {
+			return nil, fmt.Errorf("%w", f.restoreState(s, err))
+		}
+
+		ds = append(ds, f.descriptorFromRaw(&f.rds[is[i]]))
+	}
+
+	f.h.ModifiedAt = co.t.Unix()
+
+	if err := f.commit(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return ds, nil
+}
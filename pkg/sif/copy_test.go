@@ -0,0 +1,240 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+)
+
+func TestFileImage_CopyObjects(t *testing.T) {
+	tests := []struct {
+		name       string
+		createOpts []CreateOpt
+		path       string
+		fn         DescriptorSelectorFunc
+		opts       []CopyOpt
+		wantIDs    []uint32
+		wantErr    error
+	}{
+		{
+			name: "ErrObjectNotFound",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			path:    filepath.Join(corpus, "one-group.sif"),
+			fn:      WithDataType(DataSBOM),
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name: "ErrUnresolvedLink",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			path:    filepath.Join(corpus, "one-group-signed-dsse.sif"),
+			fn:      WithDataType(DataSignature),
+			wantErr: errUnresolvedLink,
+		},
+		{
+			name: "ErrInsufficientCapacity",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(1),
+			},
+			path:    filepath.Join(corpus, "one-group.sif"),
+			fn:      WithGroupID(1),
+			wantErr: errInsufficientCapacity,
+		},
+		{
+			name: "ErrPrimaryPartition",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+				),
+			},
+			path:    filepath.Join(corpus, "one-group.sif"),
+			fn:      WithGroupID(1),
+			wantErr: errPrimaryPartition,
+		},
+		{
+			name: "Group",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			path:    filepath.Join(corpus, "one-group.sif"),
+			fn:      WithGroupID(1),
+			wantIDs: []uint32{1, 2},
+		},
+		{
+			name: "Signed",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			},
+			path: filepath.Join(corpus, "two-groups-signed-dsse.sif"),
+			fn: func(d Descriptor) (bool, error) {
+				if id, isGroup := d.LinkedID(); isGroup && id == 2 {
+					return true, nil
+				}
+				return d.GroupID() == 2, nil
+			},
+			wantIDs: []uint32{2, 3},
+		},
+		{
+			name: "NoGroup",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			path:    filepath.Join(corpus, "one-object-sbom.sif"),
+			fn:      WithDataType(DataSBOM),
+			wantIDs: []uint32{1},
+		},
+		{
+			name: "OCIBlob",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			path:    filepath.Join(corpus, "one-object-oci-blob.sif"),
+			fn:      WithDataType(DataOCIBlob),
+			wantIDs: []uint32{1},
+		},
+		{
+			name: "DescriptorGrowth",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(1),
+			},
+			path: filepath.Join(corpus, "one-group.sif"),
+			fn:   WithGroupID(1),
+			opts: []CopyOpt{
+				OptCopyWithDescriptorGrowth(1),
+			},
+			wantIDs: []uint32{1, 2},
+		},
+		{
+			name: "WithTime",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+			path: filepath.Join(corpus, "one-group.sif"),
+			fn:   WithID(1),
+			opts: []CopyOpt{
+				OptCopyWithTime(time.Unix(946702800, 0)),
+			},
+			wantIDs: []uint32{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := LoadContainerFromPath(tt.path, OptLoadWithFlag(os.O_RDONLY))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				if err := src.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+
+			var b Buffer
+
+			f, err := CreateContainer(&b, tt.createOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ds, err := f.CopyObjects(src, tt.fn, tt.opts...)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			var ids []uint32
+			for _, d := range ds {
+				ids = append(ids, d.ID())
+			}
+
+			if got, want := ids, tt.wantIDs; !slices.Equal(got, want) {
+				t.Errorf("got IDs %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_CopyObjectsSameImage(t *testing.T) {
+	var b Buffer
+
+	f, err := CreateContainer(&b,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptorCapacity(2),
+		OptCreateWithDescriptors(
+			getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+			getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}, OptLinkedID(1)),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := f.CopyObjects(f, WithGroupID(1), OptCopyWithDescriptorGrowth(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, d := range ds {
+		orig, err := f.GetDescriptor(WithID(uint32(i) + 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := orig.GetData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := d.GetData(); err != nil {
+			t.Fatal(err)
+		} else if !slices.Equal(got, want) {
+			t.Errorf("object %v: got data %v, want %v", d.ID(), got, want)
+		}
+
+		if got, want := d.GroupID(), uint32(2); got != want {
+			t.Errorf("object %v: got group ID %v, want %v", d.ID(), got, want)
+		}
+	}
+
+	if linkedID, isGroup := ds[1].LinkedID(); linkedID != ds[0].ID() || isGroup {
+		t.Errorf("got linked ID %v (group %v), want %v", linkedID, isGroup, ds[0].ID())
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Error(err)
+	}
+
+	g := goldie.New(t, goldie.WithTestNameForDir(true))
+	g.Assert(t, t.Name(), b.Bytes())
+}
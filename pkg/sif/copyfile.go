@@ -0,0 +1,199 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"io"
+	"os"
+)
+
+// fileRange describes a region of a regular file that remains to be read by a reader.
+type fileRange struct {
+	s   io.Seeker // Reader of the region.
+	pos int64     // Position of s corresponding to the start of the region.
+	fp  *os.File
+	off int64
+	n   int64
+}
+
+// readerRange returns the region of a regular file that remains to be read by r, if r reads
+// directly from a regular file.
+func readerRange(r io.Reader) (fileRange, bool) {
+	switch r := r.(type) {
+	case *os.File:
+		fi, err := r.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return fileRange{}, false
+		}
+
+		off, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fileRange{}, false
+		}
+
+		return fileRange{r, off, r, off, max(fi.Size()-off, 0)}, true
+
+	case *io.SectionReader:
+		ra, base, n := r.Outer()
+
+		var fp *os.File
+		switch ra := ra.(type) {
+		case *os.File:
+			fp = ra
+		case *mappedFile:
+			fp = ra.fp
+		default:
+			return fileRange{}, false
+		}
+
+		if fi, err := fp.Stat(); err != nil || !fi.Mode().IsRegular() {
+			return fileRange{}, false
+		}
+
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fileRange{}, false
+		}
+
+		return fileRange{r, pos, fp, base + pos, max(n-pos, 0)}, true
+	}
+
+	return fileRange{}, false
+}
+
+// copyData copies data from r to w, which is positioned at offset, and returns the number of bytes
+// copied. If h is not nil, the data copied is also written to h.
+//
+// If w is a regular file, and r reads directly from a regular file, the data is copied using
+// copyFile, which preserves holes, and avoids copying the data through user space where supported.
+func copyData(w io.Writer, offset int64, r io.Reader, h io.Writer) (int64, error) {
+	// Cancellation and progress reporting are handled directly when copying between files.
+	var p *progress
+	src := r
+	if pr, ok := r.(*progressReader); ok {
+		p, src = pr.p, pr.r
+	}
+
+	if dst, ok := w.(*os.File); ok {
+		if fr, ok := readerRange(src); ok {
+			n, err := copyFile(dst, offset, fr, p)
+			if err != nil {
+				return n, err
+			}
+			return n, finishCopy(dst, offset, fr, n, h)
+		}
+	}
+
+	if h != nil {
+		r = io.TeeReader(r, h)
+	}
+
+	return io.Copy(w, r)
+}
+
+// copyFile copies the region fr to dst at offset, and returns the number of bytes copied. The
+// region is cloned where supported, so that data is shared until modified. Otherwise, each data
+// segment of the region is copied by the kernel where supported, or through user space, and each
+// hole is reproduced in dst where possible, so that holes remain unallocated.
+//
+// If the regions of fr and dst overlap within the same file, offset must be less than fr.off.
+//
+// If p is not nil, p is checked for cancellation, and progress is reported to p.
+func copyFile(dst *os.File, offset int64, fr fileRange, p *progress) (int64, error) {
+	if err := p.check(); err != nil {
+		return 0, err
+	}
+
+	if cloneRange(dst, offset, fr) {
+		p.add(fr.n)
+		return fr.n, nil
+	}
+
+	fi, err := dst.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := fi.Size()
+
+	segs, err := fileSegments(fr.fp, fr.off, fr.n)
+	if err != nil {
+		return 0, err
+	}
+
+	var copied int64
+
+	for _, s := range segs {
+		at := offset + s.off - fr.off
+
+		if !s.hole {
+			n, err := copySegment(dst, at, fileRange{fp: fr.fp, off: s.off, n: s.n}, p)
+			copied += n
+			if err != nil || n < s.n {
+				return copied, err
+			}
+			continue
+		}
+
+		// Only the portion of the hole that overlaps existing content of dst needs clearing.
+		if at < size {
+			if err := clearRange(dst, at, min(s.n, size-at)); err != nil {
+				return copied, err
+			}
+		}
+
+		copied += s.n
+		p.add(s.n)
+	}
+
+	// If the region ends with a hole, dst may need to be extended.
+	if end := offset + copied; end > size {
+		if fi, err := dst.Stat(); err != nil {
+			return copied, err
+		} else if fi.Size() < end {
+			return copied, dst.Truncate(end)
+		}
+	}
+
+	return copied, nil
+}
+
+// copySegment copies the region fr to dst at offset. The region is copied by the kernel where
+// supported, and otherwise through user space.
+func copySegment(dst *os.File, offset int64, fr fileRange, p *progress) (int64, error) {
+	if n, ok, err := copyRange(dst, offset, fr, p); ok {
+		return n, err
+	}
+
+	if _, err := dst.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.Copy(dst, &progressReader{r: io.NewSectionReader(fr.fp, fr.off, fr.n), p: p})
+}
+
+// finishCopy positions dst and the reader of fr following the copy of n bytes of fr to dst at
+// offset. If h is not nil, the data copied is written to h.
+func finishCopy(dst *os.File, offset int64, fr fileRange, n int64, h io.Writer) error {
+	if _, err := fr.s.Seek(fr.pos+n, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := dst.Seek(offset+n, io.SeekStart); err != nil {
+		return err
+	}
+
+	if h != nil {
+		if _, err := io.Copy(h, io.NewSectionReader(dst, offset, n)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyChunkSize is the maximum number of bytes copied by each call to copy_file_range(2), which
+// bounds the time between checks for cancellation.
+const copyChunkSize = 64 << 20
+
+// withFds calls fn with the file descriptors of a and b.
+func withFds(a, b *os.File, fn func(afd, bfd int)) error {
+	arc, err := a.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	brc, err := b.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var bErr error
+
+	if err := arc.Control(func(afd uintptr) {
+		bErr = brc.Control(func(bfd uintptr) {
+			fn(int(afd), int(bfd)) //nolint:gosec // File descriptors fit in an int.
+		})
+	}); err != nil {
+		return err
+	}
+
+	return bErr
+}
+
+// canClone reports whether the region fr can be cloned to dst at offset. The source and
+// destination offsets must be aligned to the block size of dst, as must the length of the region,
+// unless it extends to the end of the source file.
+func canClone(dst *os.File, offset int64, fr fileRange) bool {
+	if fr.n == 0 {
+		return false
+	}
+
+	fi, err := dst.Stat()
+	if err != nil {
+		return false
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	bs := int64(st.Blksize)
+	if bs <= 0 || offset%bs != 0 || fr.off%bs != 0 {
+		return false
+	}
+
+	if fr.n%bs == 0 {
+		return true
+	}
+
+	fi, err = fr.fp.Stat()
+	return err == nil && fr.off+fr.n == fi.Size()
+}
+
+// cloneRange clones the region fr to dst at offset using the FICLONERANGE ioctl, so that data is
+// shared until modified. It returns false if cloning is not supported by the filesystem or not
+// permitted by alignment, in which case no data is copied.
+func cloneRange(dst *os.File, offset int64, fr fileRange) bool {
+	if !canClone(dst, offset, fr) {
+		return false
+	}
+
+	var cloneErr error
+
+	err := withFds(dst, fr.fp, func(dfd, sfd int) {
+		cloneErr = unix.IoctlFileCloneRange(dfd, &unix.FileCloneRange{
+			Src_fd:      int64(sfd),
+			Src_offset:  uint64(fr.off), //nolint:gosec // Offsets are not negative.
+			Src_length:  uint64(fr.n),   //nolint:gosec // Lengths are not negative.
+			Dest_offset: uint64(offset), //nolint:gosec // Offsets are not negative.
+		})
+	})
+
+	return err == nil && cloneErr == nil
+}
+
+// copyRange copies the region fr to dst at offset using copy_file_range(2), without copying data
+// through user space. If copy_file_range(2) is not supported for these files, false is returned,
+// and no data is copied.
+//
+// If p is not nil, p is checked for cancellation, and progress is reported to p.
+func copyRange(dst *os.File, offset int64, fr fileRange, p *progress) (int64, bool, error) {
+	var copied int64
+
+	for copied < fr.n {
+		if err := p.check(); err != nil {
+			return copied, true, err
+		}
+
+		var n int
+		var copyErr error
+
+		if err := withFds(dst, fr.fp, func(dfd, sfd int) {
+			roff, woff := fr.off+copied, offset+copied
+			n, copyErr = unix.CopyFileRange(sfd, &roff, dfd, &woff, int(min(fr.n-copied, copyChunkSize)), 0)
+		}); err != nil {
+			return copied, true, err
+		}
+
+		// If copy_file_range(2) is not supported for these files, fall back to copying through user
+		// space, provided no data has been copied.
+		if copyErr != nil && copied == 0 && isCopyUnsupported(copyErr) {
+			return 0, false, nil
+		}
+
+		if errors.Is(copyErr, unix.EINTR) {
+			continue
+		}
+
+		if copyErr != nil {
+			return copied, true, copyErr
+		}
+
+		// The source file is shorter than expected.
+		if n == 0 {
+			break
+		}
+
+		copied += int64(n)
+		p.add(int64(n))
+	}
+
+	return copied, true, nil
+}
+
+// isCopyUnsupported reports whether err indicates that copy_file_range(2) is not supported for
+// the files involved.
+func isCopyUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) ||
+		errors.Is(err, unix.EXDEV) ||
+		errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EPERM)
+}
@@ -0,0 +1,23 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !linux
+
+package sif
+
+import "os"
+
+// cloneRange is not supported on platforms other than Linux, and always returns false.
+func cloneRange(*os.File, int64, fileRange) bool {
+	return false
+}
+
+// copyRange is not supported on platforms other than Linux, and always returns false.
+func copyRange(*os.File, int64, fileRange, *progress) (int64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTempFile writes b to a temporary file, and returns the file opened for reading.
+func writeTempFile(t *testing.T, b []byte) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "data")
+
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fp.Close() })
+
+	return fp
+}
+
+func TestCopyData(t *testing.T) {
+	// Large enough to span multiple filesystem blocks, and not block aligned.
+	data := bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef, 0xfa}, 10000)
+
+	tests := []struct {
+		name     string
+		r        func(t *testing.T) io.Reader
+		offset   int64
+		wantData []byte
+	}{
+		{
+			name:     "Reader",
+			r:        func(*testing.T) io.Reader { return bytes.NewReader(data) },
+			wantData: data,
+		},
+		{
+			name:     "File",
+			r:        func(t *testing.T) io.Reader { return writeTempFile(t, data) },
+			wantData: data,
+		},
+		{
+			name:     "FileAligned",
+			r:        func(t *testing.T) io.Reader { return writeTempFile(t, data) },
+			offset:   4096,
+			wantData: data,
+		},
+		{
+			name: "FilePartiallyRead",
+			r: func(t *testing.T) io.Reader {
+				fp := writeTempFile(t, data)
+				if _, err := fp.Seek(3, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+				return fp
+			},
+			wantData: data[3:],
+		},
+		{
+			name: "SectionReader",
+			r: func(t *testing.T) io.Reader {
+				return io.NewSectionReader(writeTempFile(t, data), 7, 4096)
+			},
+			wantData: data[7 : 7+4096],
+		},
+		{
+			name: "SectionReaderPartiallyRead",
+			r: func(t *testing.T) io.Reader {
+				sr := io.NewSectionReader(writeTempFile(t, data), 7, 4096)
+				if _, err := sr.Seek(5, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+				return sr
+			},
+			wantData: data[12 : 7+4096],
+		},
+		{
+			name: "SectionReaderMapped",
+			r: func(t *testing.T) io.Reader {
+				m, err := newMappedFile(writeTempFile(t, data))
+				if errors.Is(err, errMmapUnsupported) {
+					t.Skip(err)
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				return io.NewSectionReader(m, 4096, 8192)
+			},
+			wantData: data[4096 : 4096+8192],
+		},
+		{
+			name: "SectionReaderBeyondEOF",
+			r: func(t *testing.T) io.Reader {
+				return io.NewSectionReader(writeTempFile(t, data), int64(len(data))-2, 10)
+			},
+			wantData: data[len(data)-2:],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := os.Create(filepath.Join(t.TempDir(), "dst"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+
+			if _, err := dst.Seek(tt.offset, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+
+			var written int64
+			p := newProgress(context.Background(), func(n, _ int64) { written = n }, -1)
+
+			r := tt.r(t)
+			h := sha256.New()
+
+			n, err := copyData(dst, tt.offset, p.reader(r), h)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := n, int64(len(tt.wantData)); got != want {
+				t.Errorf("got %v bytes copied, want %v", got, want)
+			}
+
+			if got, want := written, int64(len(tt.wantData)); got != want {
+				t.Errorf("got %v bytes reported, want %v", got, want)
+			}
+
+			if got, want := h.Sum(nil), sha256.Sum256(tt.wantData); !bytes.Equal(got, want[:]) {
+				t.Errorf("got digest %x, want %x", got, want)
+			}
+
+			// The source and destination must be positioned following the data copied.
+			if pos, err := dst.Seek(0, io.SeekCurrent); err != nil {
+				t.Fatal(err)
+			} else if got, want := pos, tt.offset+n; got != want {
+				t.Errorf("got destination position %v, want %v", got, want)
+			}
+
+			if b, err := io.ReadAll(r); err != nil {
+				t.Fatal(err)
+			} else if len(b) > 0 {
+				t.Errorf("got %v bytes remaining in source", len(b))
+			}
+
+			b, err := os.ReadFile(dst.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := b[tt.offset:], tt.wantData; !bytes.Equal(got, want) {
+				t.Errorf("got data %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileImage_AddObjectFromFile(t *testing.T) {
+	data := bytes.Repeat([]byte{0xfe, 0xed}, 10000)
+
+	f, err := CreateContainerAtPath(filepath.Join(t.TempDir(), "image.sif"), OptCreateDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	partition, err := NewDescriptorInput(DataPartition, writeTempFile(t, data),
+		OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := NewDescriptorInput(DataOCIBlob, writeTempFile(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, di := range []DescriptorInput{partition, blob} {
+		if err := f.AddObject(di); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ds, err := f.GetDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range ds {
+		b, err := d.GetData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, data) {
+			t.Errorf("object %v: data mismatch", d.ID())
+		}
+	}
+
+	if got, want := ds[0].Offset()%4096, int64(0); got != want {
+		t.Errorf("got partition offset remainder %v, want %v", got, want)
+	}
+
+	h, err := ds[1].OCIBlobDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := h.Hex, sha256.Sum256(data); got != hex.EncodeToString(want[:]) {
+		t.Errorf("got digest %v, want %x", got, want)
+	}
+}
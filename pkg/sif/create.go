@@ -12,12 +12,15 @@
 package sif
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -42,6 +45,20 @@ func nextAligned(offset int64, alignment int) (int64, error) {
 	return offset + align64, nil
 }
 
+// maxInferredAlignment is the largest alignment that will be inferred for an existing data object.
+const maxInferredAlignment = 4096
+
+// inferAlignment returns the alignment of a data object located at offset. The original alignment
+// requirement of a data object is not recorded in the image, so the largest power of two (up to
+// maxInferredAlignment) that divides offset is assumed.
+func inferAlignment(offset int64) int {
+	alignment := 1
+	for alignment < maxInferredAlignment && offset%int64(alignment*2) == 0 {
+		alignment *= 2
+	}
+	return alignment
+}
+
 // writeDataObjectAt writes the data object described by di to ws, using time t, recording details
 // in d. The object is written at the first position that satisfies the alignment requirements
 // described by di following offsetUnaligned.
@@ -55,7 +72,7 @@ func writeDataObjectAt(ws io.WriteSeeker, offsetUnaligned int64, di DescriptorIn
 		return err
 	}
 
-	n, err := io.Copy(ws, di.r)
+	n, err := copyData(ws, offset, di.r, di.h)
 	if err != nil {
 		return err
 	}
@@ -73,66 +90,165 @@ func writeDataObjectAt(ws io.WriteSeeker, offsetUnaligned int64, di DescriptorIn
 
 // calculatedDataSize calculates the size of the data section based on the in-use descriptors.
 func (f *FileImage) calculatedDataSize() int64 {
-	dataEnd := f.DataOffset()
+	dataEnd := f.h.DataOffset
 
-	f.WithDescriptors(func(d Descriptor) bool {
-		if objectEnd := d.Offset() + d.Size(); dataEnd < objectEnd {
+	for _, rd := range f.rds {
+		if objectEnd := rd.Offset + rd.Size; rd.Used && dataEnd < objectEnd {
 			dataEnd = objectEnd
 		}
-		return false
-	})
+	}
 
-	return dataEnd - f.DataOffset()
+	return dataEnd - f.h.DataOffset
+}
+
+// dataEnd returns the offset at which new data may be written to f. When modifications are being
+// staged by Batch, data is never written below the size of the image when Batch was called, so
+// that data objects deleted by the batch are retained if it is rolled back.
+func (f *FileImage) dataEnd() int64 {
+	return max(f.h.DataOffset+f.calculatedDataSize(), f.batchSize)
 }
 
 var (
 	errInsufficientCapacity = errors.New("insufficient descriptor capacity to add data object(s) to image")
-	errPrimaryPartition     = errors.New("image already contains a primary partition")
+	errPrimaryPartition     = errors.New("image already contains a primary partition for architecture")
 	errObjectIDOverflow     = errors.New("object ID would overflow")
 )
 
-// writeDataObject writes the data object described by di to f, using time t, recording details in
-// the descriptor at index i.
-func (f *FileImage) writeDataObject(i int, di DescriptorInput, t time.Time) error {
+// checkPrimaryPartition returns an error if f contains a primary system partition for CPU
+// architecture arch.
+func (f *FileImage) checkPrimaryPartition(arch archType) error {
+	for _, rd := range f.rds {
+		if !rd.Used || !rd.isPartitionOfType(PartPrimSys) {
+			continue
+		}
+
+		var p partition
+		if err := rd.getExtra(binaryUnmarshaler{&p}); err == nil && p.Arch == arch {
+			return fmt.Errorf("%w: %v", errPrimaryPartition, arch.GoArch())
+		}
+	}
+	return nil
+}
+
+// allocateDescriptor prepares the descriptor at index i in f to record the details of the data
+// object described by di.
+func (f *FileImage) allocateDescriptor(i int, di DescriptorInput) (*rawDescriptor, error) {
 	if i >= len(f.rds) {
-		return errInsufficientCapacity
+		return nil, errInsufficientCapacity
 	}
 
 	// We derive the ID from i, so make sure the ID will not overflow.
 	if int64(i) >= math.MaxUint32 {
-		return errObjectIDOverflow
+		return nil, errObjectIDOverflow
 	}
 
-	// If this is a primary partition, verify there isn't another primary partition, and update the
-	// architecture in the global header.
+	// If this is a primary partition, verify there isn't another primary partition for the same
+	// architecture.
 	if p, ok := di.opts.md.(partition); ok && p.Parttype == PartPrimSys {
-		if ds, err := f.GetDescriptors(WithPartitionType(PartPrimSys)); err == nil && len(ds) > 0 {
-			return errPrimaryPartition
+		if err := f.checkPrimaryPartition(p.Arch); err != nil {
+			return nil, err
 		}
-
-		f.h.Arch = p.Arch
 	}
 
 	d := &f.rds[i]
 	d.ID = uint32(i) + 1 //nolint:gosec // Overflow handled above.
 
-	f.h.DataSize = f.calculatedDataSize()
-
-	if err := writeDataObjectAt(f.rw, f.h.DataOffset+f.h.DataSize, di, t, d); err != nil {
-		return err
-	}
+	return d, nil
+}
 
+// commitDescriptor updates the global header and minimum object ID map of f to account for the
+// newly written data object described by d.
+func (f *FileImage) commitDescriptor(d *rawDescriptor) {
 	// Update minimum object ID map.
 	if minID, ok := f.minIDs[d.GroupID]; !ok || d.ID < minID {
 		f.minIDs[d.GroupID] = d.ID
 	}
 
+	// If this is a primary partition, update the architecture in the global header.
+	if d.isPartitionOfType(PartPrimSys) {
+		f.h.Arch = f.primaryArch()
+	}
+
 	f.h.DescriptorsFree--
 	f.h.DataSize += d.SizeWithPadding
+}
+
+// writeDataObject writes the data object described by di to f, using time t, recording details in
+// the descriptor at index i.
+func (f *FileImage) writeDataObject(i int, di DescriptorInput, t time.Time) error {
+	d, err := f.allocateDescriptor(i, di)
+	if err != nil {
+		return err
+	}
+
+	f.h.DataSize = f.dataEnd() - f.h.DataOffset
+
+	if err := writeDataObjectAt(f.rw, f.h.DataOffset+f.h.DataSize, di, t, d); err != nil {
+		return err
+	}
+
+	f.commitDescriptor(d)
 
 	return nil
 }
 
+// savedState records the state of an image prior to a modification.
+type savedState struct {
+	size   int64
+	h      header
+	rds    []rawDescriptor
+	minIDs map[uint32]uint32
+}
+
+// saveState returns the current state of f, so that a failed modification can be rolled back using
+// restoreState.
+func (f *FileImage) saveState() (savedState, error) {
+	size, err := f.size()
+	if err != nil {
+		return savedState{}, err
+	}
+
+	return savedState{size, f.h, slices.Clone(f.rds), maps.Clone(f.minIDs)}, nil
+}
+
+// restoreState restores the state of f recorded in s following a modification that failed with
+// err, and truncates data written since s was recorded. The error is returned, along with any
+// error encountered while truncating.
+func (f *FileImage) restoreState(s savedState, err error) error {
+	f.h, f.rds, f.minIDs = s.h, s.rds, s.minIDs
+
+	if terr := f.rw.Truncate(s.size); terr != nil {
+		return errors.Join(err, terr)
+	}
+	return err
+}
+
+// moveData copies n bytes from offset src to offset dst in the backing storage of f. If the source
+// and destination regions overlap, dst must be less than src. On success, the offset of the backing
+// storage is set to the end of the destination region.
+//
+// If the backing storage is a regular file, holes in the source region are preserved.
+func (f *FileImage) moveData(dst, src, n int64) error {
+	if fp, ok := f.rw.(*os.File); ok {
+		if copied, err := copyFile(fp, dst, fileRange{fp: fp, off: src, n: n}, nil); err != nil {
+			return err
+		} else if copied < n {
+			return io.ErrUnexpectedEOF
+		}
+
+		// copyFile does not use the file offset, so set it explicitly.
+		_, err := f.rw.Seek(dst+n, io.SeekStart)
+		return err
+	}
+
+	if _, err := f.rw.Seek(dst, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := io.CopyN(f.rw, io.NewSectionReader(f.rw, src, n), n)
+	return err
+}
+
 // writeDescriptors writes the descriptors in f to backing storage.
 func (f *FileImage) writeDescriptors() error {
 	if _, err := f.rw.Seek(f.h.DescriptorsOffset, io.SeekStart); err != nil {
@@ -160,6 +276,10 @@ type createOpts struct {
 	dis                []DescriptorInput
 	t                  time.Time
 	closeOnUnload      bool
+	journaled          bool
+	lock               lockOpts
+	ctx                context.Context //nolint:containedctx
+	progress           ProgressFunc
 }
 
 // CreateOpt are used to specify container creation options.
@@ -235,10 +355,88 @@ func OptCreateWithCloseOnUnload(b bool) CreateOpt {
 	}
 }
 
+// OptCreateWithContext specifies that writing data objects should stop if ctx is done. If ctx is
+// done before all data objects are written, the error from ctx is returned.
+func OptCreateWithContext(ctx context.Context) CreateOpt {
+	return func(co *createOpts) error {
+		co.ctx = ctx
+		return nil
+	}
+}
+
+// OptCreateWithProgress specifies that fn should be called to report progress as data objects are
+// written.
+func OptCreateWithProgress(fn ProgressFunc) CreateOpt {
+	return func(co *createOpts) error {
+		co.progress = fn
+		return nil
+	}
+}
+
+// OptCreateWithJournal specifies whether subsequent modifications to the image should be
+// journaled. When journaling is enabled, an interrupted modification leaves the image in either
+// its original or modified state. By default, modifications are not journaled.
+func OptCreateWithJournal(b bool) CreateOpt {
+	return func(co *createOpts) error {
+		co.journaled = b
+		return nil
+	}
+}
+
+// OptCreateWithLock specifies whether CreateContainerAtPath should place an exclusive advisory
+// lock on the container file until the image is unloaded. By default, the file is not locked, and
+// when locking is enabled, CreateContainerAtPath waits until the lock is acquired.
+func OptCreateWithLock(b bool) CreateOpt {
+	return func(co *createOpts) error {
+		co.lock.lock = b
+		return nil
+	}
+}
+
+// OptCreateWithLockTimeout specifies that CreateContainerAtPath should place an exclusive advisory
+// lock on the container file, as with OptCreateWithLock, waiting at most d for the lock to be
+// acquired. If d is zero, CreateContainerAtPath does not wait.
+func OptCreateWithLockTimeout(d time.Duration) CreateOpt {
+	return func(co *createOpts) error {
+		if d < 0 {
+			return errInvalidLockTimeout
+		}
+		co.lock = lockOpts{lock: true, timed: true, timeout: d}
+		return nil
+	}
+}
+
+// getCreateOpts returns container creation options populated with default values, and modified
+// according to opts.
+func getCreateOpts(opts ...CreateOpt) (createOpts, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return createOpts{}, err
+	}
+
+	co := createOpts{
+		id:                 id,
+		descriptorsOffset:  4096,
+		descriptorCapacity: 48,
+		t:                  time.Now(),
+		closeOnUnload:      true,
+		ctx:                context.Background(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(&co); err != nil {
+			return createOpts{}, err
+		}
+	}
+
+	return co, nil
+}
+
 var errDescriptorCapacityNotSupported = errors.New("descriptor capacity not supported")
 
-// createContainer creates a new SIF container file in rw, according to opts.
-func createContainer(rw ReadWriter, co createOpts) (*FileImage, error) {
+// newFileImage returns a FileImage backed by rw, with a global header and descriptors populated
+// according to co. No data is written to rw.
+func newFileImage(rw ReadWriter, co createOpts) (*FileImage, error) {
 	// The supported number of descriptors is limited by the unsigned 32-bit ID field in each
 	// rawDescriptor.
 	if co.descriptorCapacity >= math.MaxUint32 {
@@ -264,14 +462,33 @@ func createContainer(rw ReadWriter, co createOpts) (*FileImage, error) {
 	}
 
 	f := &FileImage{
-		rw:     rw,
-		h:      h,
-		rds:    rds,
-		minIDs: make(map[uint32]uint32),
+		rw:        rw,
+		h:         h,
+		rds:       rds,
+		journaled: co.journaled,
+		minIDs:    make(map[uint32]uint32),
+	}
+
+	return f, nil
+}
+
+// createContainer creates a new SIF container file in rw, according to opts.
+func createContainer(rw ReadWriter, co createOpts) (*FileImage, error) {
+	f, err := newFileImage(rw, co)
+	if err != nil {
+		return nil, err
 	}
 
+	p := newProgress(co.ctx, co.progress, inputSize(co.dis))
+
 	for i, di := range co.dis {
+		di.r = p.reader(di.r)
+
 		if err := f.writeDataObject(i, di, co.t); err != nil {
+			// Discard the partially written image.
+			if terr := rw.Truncate(0); terr != nil {
+				return nil, errors.Join(err, terr)
+			}
 			return nil, err
 		}
 	}
@@ -304,24 +521,18 @@ func createContainer(rw ReadWriter, co createOpts) (*FileImage, error) {
 // OptCreateWithDescriptorCapacity.
 //
 // A launch script can optionally be set using OptCreateWithLaunchScript.
+//
+// By default, subsequent modifications to the image are not journaled. To ensure an interrupted
+// modification leaves the image in either its original or modified state, consider using
+// OptCreateWithJournal.
+//
+// To stop writing large data objects on request, consider using OptCreateWithContext. To report
+// progress as data objects are written, consider using OptCreateWithProgress. If writing a data
+// object fails, rw is truncated.
 func CreateContainer(rw ReadWriter, opts ...CreateOpt) (*FileImage, error) {
-	id, err := uuid.NewRandom()
+	co, err := getCreateOpts(opts...)
 	if err != nil {
-		return nil, err
-	}
-
-	co := createOpts{
-		id:                 id,
-		descriptorsOffset:  4096,
-		descriptorCapacity: 48,
-		t:                  time.Now(),
-		closeOnUnload:      true,
-	}
-
-	for _, opt := range opts {
-		if err := opt(&co); err != nil {
-			return nil, fmt.Errorf("%w", err)
-		}
+		return nil, fmt.Errorf("%w", err)
 	}
 
 	f, err := createContainer(rw, co)
@@ -349,18 +560,51 @@ func CreateContainer(rw ReadWriter, opts ...CreateOpt) (*FileImage, error) {
 // OptCreateWithDescriptorCapacity.
 //
 // A launch script can optionally be set using OptCreateWithLaunchScript.
+//
+// By default, subsequent modifications to the image are not journaled. To ensure an interrupted
+// modification leaves the image in either its original or modified state, consider using
+// OptCreateWithJournal.
+//
+// To stop writing large data objects on request, consider using OptCreateWithContext. To report
+// progress as data objects are written, consider using OptCreateWithProgress. If writing a data
+// object fails, the file is removed.
+//
+// By default, the file is not locked. To prevent concurrent modification of the image by
+// cooperating processes, consider using OptCreateWithLock or OptCreateWithLockTimeout. If the lock
+// cannot be acquired within the specified timeout, an error wrapping ErrImageLocked is returned,
+// and the file is not modified. Locking is supported on Unix-like platforms only, and has no
+// effect elsewhere.
 func CreateContainerAtPath(path string, opts ...CreateOpt) (*FileImage, error) {
-	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o755)
+	co, err := getCreateOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
 
-	f, err := CreateContainer(fp, opts...)
+	// The file is truncated only once locked, so that an image locked by another process is not
+	// modified.
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err := lockFile(fp, true, co.lock); err != nil {
+		fp.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err := fp.Truncate(0); err != nil {
+		fp.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	f, err := createContainer(fp, co)
 	if err != nil {
 		fp.Close()
 		os.Remove(fp.Name())
 
-		return nil, err
+		return nil, fmt.Errorf("%w", err)
 	}
 
 	f.closeOnUnload = true
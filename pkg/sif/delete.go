@@ -14,6 +14,7 @@ package sif
 import (
 	"fmt"
 	"io"
+	"os"
 	"time"
 )
 
@@ -35,9 +36,21 @@ func (f *FileImage) zero(d *rawDescriptor) error {
 	return err
 }
 
+// punch deallocates the data object described by d by punching a hole in the backing storage, so
+// that it reads as zero bytes. If the backing storage does not support this, the data object is
+// overwritten with zero bytes.
+func (f *FileImage) punch(d *rawDescriptor) error {
+	if fp, ok := f.rw.(*os.File); ok {
+		return clearRange(fp, d.Offset, d.Size)
+	}
+
+	return f.zero(d)
+}
+
 // deleteOpts accumulates object deletion options.
 type deleteOpts struct {
 	zero    bool
+	punch   bool
 	compact bool
 	t       time.Time
 }
@@ -53,6 +66,18 @@ func OptDeleteZero(b bool) DeleteOpt {
 	}
 }
 
+// OptDeletePunchHole specifies whether the space occupied by the deleted object should be
+// deallocated by punching a hole in the backing storage, so that the data region of the deleted
+// object reads as zero bytes without occupying disk blocks. This is supported for images backed by
+// regular files on filesystems that support fallocate(2) with FALLOC_FL_PUNCH_HOLE. Otherwise, the
+// deleted object is zeroed, as if by OptDeleteZero.
+func OptDeletePunchHole(b bool) DeleteOpt {
+	return func(do *deleteOpts) error {
+		do.punch = b
+		return nil
+	}
+}
+
 // OptDeleteCompact specifies whether the image should be compacted following object deletion.
 func OptDeleteCompact(b bool) DeleteOpt {
 	return func(do *deleteOpts) error {
@@ -81,8 +106,9 @@ func OptDeleteWithTime(t time.Time) DeleteOpt {
 // DeleteObject deletes the data object with id, according to opts. If no matching descriptor is
 // found, an error wrapping ErrObjectNotFound is returned.
 //
-// To zero the data region of the deleted object, use OptDeleteZero. To remove unused space at the
-// end of the FileImage following object deletion, use OptDeleteCompact.
+// To zero the data region of the deleted object, use OptDeleteZero. To deallocate the data region
+// of the deleted object, use OptDeletePunchHole. To remove unused space at the end of the
+// FileImage following object deletion, use OptDeleteCompact.
 //
 // By default, the image modification time is set to the current time for non-deterministic images,
 // and unset otherwise. To override this, consider using OptDeleteDeterministic or
@@ -94,13 +120,21 @@ func (f *FileImage) DeleteObject(id uint32, opts ...DeleteOpt) error {
 // DeleteObjects deletes the data objects selected by fn, according to opts. If no descriptors are
 // selected by fns, an error wrapping ErrObjectNotFound is returned.
 //
-// To zero the data region of the deleted object, use OptDeleteZero. To remove unused space at the
-// end of the FileImage following object deletion, use OptDeleteCompact.
+// To zero the data region of the deleted object, use OptDeleteZero. To deallocate the data region
+// of the deleted object, use OptDeletePunchHole. To remove unused space at the end of the
+// FileImage following object deletion, use OptDeleteCompact.
 //
 // By default, the image modification time is set to the current time for non-deterministic images,
 // and unset otherwise. To override this, consider using OptDeleteDeterministic or
 // OptDeleteWithTime.
 func (f *FileImage) DeleteObjects(fn DescriptorSelectorFunc, opts ...DeleteOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
 	do := deleteOpts{}
 
 	if !f.isDeterministic() {
@@ -113,24 +147,16 @@ func (f *FileImage) DeleteObjects(fn DescriptorSelectorFunc, opts ...DeleteOpt)
 		}
 	}
 
-	var selected bool
+	var deleted []rawDescriptor
+	var primary bool
 
 	if err := f.withDescriptors(fn, func(d *rawDescriptor) error {
-		selected = true
-
-		if do.zero {
-			if err := f.zero(d); err != nil {
-				return fmt.Errorf("%w", err)
-			}
-		}
+		deleted = append(deleted, *d)
 
 		f.h.DescriptorsFree++
 
-		// If we remove the primary partition, set the global header Arch field to HdrArchUnknown
-		// to indicate that the SIF file doesn't include a primary partition and no dependency
-		// on any architecture exists.
 		if d.isPartitionOfType(PartPrimSys) {
-			f.h.Arch = hdrArchUnknown
+			primary = true
 		}
 
 		// Reset rawDescripter with empty struct
@@ -141,26 +167,48 @@ func (f *FileImage) DeleteObjects(fn DescriptorSelectorFunc, opts ...DeleteOpt)
 		return fmt.Errorf("%w", err)
 	}
 
-	if !selected {
+	if len(deleted) == 0 {
 		return fmt.Errorf("%w", ErrObjectNotFound)
 	}
 
+	// If we remove a primary partition, update the global header Arch field to reflect the
+	// remaining primary partitions. If none remain, HdrArchUnknown indicates that the SIF file
+	// doesn't include a primary partition and no dependency on any architecture exists.
+	if primary {
+		f.h.Arch = f.primaryArch()
+	}
+
 	f.h.ModifiedAt = do.t.Unix()
 
 	if do.compact {
 		f.h.DataSize = f.calculatedDataSize()
-
-		if err := f.rw.Truncate(f.h.DataOffset + f.h.DataSize); err != nil {
-			return fmt.Errorf("%w", err)
-		}
 	}
 
-	if err := f.writeDescriptors(); err != nil {
+	if err := f.commit(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	if err := f.writeHeader(); err != nil {
-		return fmt.Errorf("%w", err)
+	// Data is only discarded once the updated descriptors have been written, so that an
+	// interrupted deletion does not leave descriptors referring to discarded data.
+	for i := range deleted {
+		var err error
+
+		switch {
+		case do.punch:
+			err = f.punch(&deleted[i])
+		case do.zero:
+			err = f.zero(&deleted[i])
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if do.compact {
+		if err := f.rw.Truncate(f.h.DataOffset + f.h.DataSize); err != nil {
+			return fmt.Errorf("%w", err)
+		}
 	}
 
 	return nil
@@ -90,6 +90,20 @@ func TestDeleteObject(t *testing.T) {
 				OptDeleteCompact(true),
 			},
 		},
+		{
+			name: "OnePunchHole",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+				),
+			},
+			ids: []uint32{1},
+			opts: []DeleteOpt{
+				OptDeletePunchHole(true),
+			},
+		},
 		{
 			name: "TwoZero",
 			createOpts: []CreateOpt{
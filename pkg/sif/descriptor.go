@@ -207,6 +207,8 @@ type Descriptor struct {
 	raw rawDescriptor // Raw descriptor from image.
 
 	relativeID uint32 // ID relative to minimum ID of object group.
+
+	maxSize int64 // Maximum size of data object read by GetData, or zero.
 }
 
 // DataType returns the type of data object.
@@ -355,8 +357,23 @@ func (d Descriptor) OCIBlobDigest() (v1.Hash, error) {
 	return o.digest, nil
 }
 
-// GetData returns the data object associated with descriptor d.
+// GetData returns the data object associated with descriptor d. If the image was loaded with a
+// maximum object size (see OptLoadWithMaxObjectSize) that is exceeded by the data object, an
+// error wrapping a LimitError is returned.
+//
+// If the image was mapped into memory (see OptLoadWithMmap), the returned slice refers directly
+// to the mapping. It must not be modified, and must not be used after the image is unloaded.
 func (d Descriptor) GetData() ([]byte, error) {
+	if d.maxSize > 0 && d.raw.Size > d.maxSize {
+		return nil, fmt.Errorf("%w", &LimitError{LimitObjectSize, d.raw.Size, d.maxSize})
+	}
+
+	if s, ok := d.r.(slicer); ok {
+		if b, ok := s.slice(d.raw.Offset, d.raw.Size); ok {
+			return b, nil
+		}
+	}
+
 	b := make([]byte, d.raw.Size)
 	if _, err := io.ReadFull(d.GetReader(), b); err != nil {
 		return nil, err
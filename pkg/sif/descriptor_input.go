@@ -16,6 +16,8 @@ import (
 	"fmt"
 	"io"
 	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // descriptorOpts accumulates data object options.
@@ -26,6 +28,7 @@ type descriptorOpts struct {
 	name      string
 	md        encoding.BinaryMarshaler
 	t         time.Time
+	size      int64 // Size of data object, or -1 if unknown.
 }
 
 // DescriptorInputOpt are used to specify data object options.
@@ -256,13 +259,61 @@ func OptSBOMMetadata(f SBOMFormat) DescriptorInputOpt {
 	}
 }
 
+var errInvalidOCIBlobDigest = errors.New("invalid OCI blob digest")
+
+// OptOCIBlobDigest sets the digest of an OCI blob data object to h. By default, the digest is
+// calculated as the data object is written.
+//
+// If this option is applied to a data object with an incompatible type, an error is returned.
+func OptOCIBlobDigest(h v1.Hash) DescriptorInputOpt {
+	return func(t DataType, opts *descriptorOpts) error {
+		if got := t; got != DataOCIRootIndex && got != DataOCIBlob {
+			return &unexpectedDataTypeError{got, []DataType{DataOCIRootIndex, DataOCIBlob}}
+		}
+
+		if h.Algorithm == "" || h.Hex == "" {
+			return errInvalidOCIBlobDigest
+		}
+
+		opts.md = &ociBlob{digest: h}
+		return nil
+	}
+}
+
+var errInvalidObjectSize = errors.New("invalid object size")
+
+// OptObjectSize specifies n as the size of the data object. By default, the size is determined
+// from the reader supplied to NewDescriptorInput where possible.
+func OptObjectSize(n int64) DescriptorInputOpt {
+	return func(_ DataType, opts *descriptorOpts) error {
+		if n < 0 {
+			return errInvalidObjectSize
+		}
+		opts.size = n
+		return nil
+	}
+}
+
 // DescriptorInput describes a new data object.
 type DescriptorInput struct {
 	dt   DataType
 	r    io.Reader
+	h    io.Writer // Accumulates digest as data object is written, or nil.
 	opts descriptorOpts
 }
 
+// readerSize returns the number of bytes available to be read from r, or -1 if this cannot be
+// determined.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Size() int64 }:
+		return r.Size()
+	}
+	return -1
+}
+
 // DefaultObjectGroup is the default group that data objects are placed in.
 const DefaultObjectGroup = 1
 
@@ -284,24 +335,33 @@ const DefaultObjectGroup = 1
 //
 // By default, no name is set for data object. To set a name, use OptObjectName.
 //
+// The size of the data object is determined from r where possible, which is required when writing
+// an image with WriteContainer. To specify the size explicitly, use OptObjectSize.
+//
+// The digest of a data object of type DataOCIRootIndex or DataOCIBlob is calculated as it is
+// written. To specify the digest explicitly, use OptOCIBlobDigest.
+//
+// If r is an *os.File or an *io.SectionReader that reads from an *os.File, and the image is backed
+// by an *os.File, the data object is copied by the kernel where supported, and on Linux is cloned
+// on filesystems that support reflinks (such as Btrfs and XFS) where alignment permits.
+//
 // When creating a new image, data object creation/modification times are set to the image creation
 // time. When modifying an existing image, the data object creation/modification time is set to the
 // image modification time. To override this behavior, consider using OptObjectTime.
 func NewDescriptorInput(t DataType, r io.Reader, opts ...DescriptorInputOpt) (DescriptorInput, error) {
 	dopts := descriptorOpts{
 		groupID: DefaultObjectGroup,
+		size:    readerSize(r),
 	}
 
 	if t == DataPartition {
 		dopts.alignment = 4096
 	}
 
-	// Accumulate hash for OCI blobs as they are written.
+	// Calculate digest for OCI blobs, unless overridden by opts.
+	var md *ociBlob
 	if t == DataOCIRootIndex || t == DataOCIBlob {
-		md := newOCIBlobDigest()
-
-		r = io.TeeReader(r, md.hasher)
-
+		md = newOCIBlobDigest()
 		dopts.md = md
 	}
 
@@ -317,6 +377,11 @@ func NewDescriptorInput(t DataType, r io.Reader, opts ...DescriptorInputOpt) (De
 		opts: dopts,
 	}
 
+	// Accumulate hash for OCI blobs as they are written.
+	if md != nil && dopts.md == md {
+		di.h = md.hasher
+	}
+
 	return di, nil
 }
 
@@ -17,11 +17,12 @@ import (
 	"testing"
 	"time"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/sebdah/goldie/v2"
 )
 
 // getDescriptorInput returns a new DescriptorInput of type dt with contents b, according to opts.
-func getDescriptorInput(t *testing.T, dt DataType, b []byte, opts ...DescriptorInputOpt) DescriptorInput {
+func getDescriptorInput(t testing.TB, dt DataType, b []byte, opts ...DescriptorInputOpt) DescriptorInput {
 	t.Helper()
 
 	di, err := NewDescriptorInput(dt, bytes.NewReader(b), opts...)
@@ -122,6 +123,14 @@ func TestNewDescriptorInput(t *testing.T) {
 				OptObjectTime(time.Unix(946702800, 0)),
 			},
 		},
+		{
+			name: "OptObjectSizeInvalid",
+			t:    DataGeneric,
+			opts: []DescriptorInputOpt{
+				OptObjectSize(-1),
+			},
+			wantErr: errInvalidObjectSize,
+		},
 		{
 			name: "OptMetadata",
 			t:    DataGeneric,
@@ -197,6 +206,35 @@ func TestNewDescriptorInput(t *testing.T) {
 			name: "DataOCIBlob",
 			t:    DataOCIBlob,
 		},
+		{
+			name: "OptOCIBlobDigestUnexpectedDataType",
+			t:    DataGeneric,
+			opts: []DescriptorInputOpt{
+				OptOCIBlobDigest(v1.Hash{
+					Algorithm: "sha256",
+					Hex:       "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+				}),
+			},
+			wantErr: &unexpectedDataTypeError{DataGeneric, []DataType{DataOCIRootIndex, DataOCIBlob}},
+		},
+		{
+			name: "OptOCIBlobDigestInvalid",
+			t:    DataOCIBlob,
+			opts: []DescriptorInputOpt{
+				OptOCIBlobDigest(v1.Hash{}),
+			},
+			wantErr: errInvalidOCIBlobDigest,
+		},
+		{
+			name: "OptOCIBlobDigest",
+			t:    DataOCIBlob,
+			opts: []DescriptorInputOpt{
+				OptOCIBlobDigest(v1.Hash{
+					Algorithm: "sha256",
+					Hex:       "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+				}),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
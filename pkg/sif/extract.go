@@ -0,0 +1,156 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// linkClosure returns the IDs of the data objects with the specified ids, along with the IDs of
+// any data objects they are linked to, directly or indirectly. Links to data objects that do not
+// exist are ignored.
+func (f *FileImage) linkClosure(ids []uint32) map[uint32]bool {
+	byID := make(map[uint32]*rawDescriptor)
+	for i := range f.rds {
+		if rd := &f.rds[i]; rd.Used {
+			byID[rd.ID] = rd
+		}
+	}
+
+	closure := make(map[uint32]bool)
+
+	for len(ids) > 0 {
+		id := ids[0]
+		ids = ids[1:]
+
+		rd, ok := byID[id]
+		if !ok || closure[id] {
+			continue
+		}
+
+		closure[id] = true
+
+		if rd.LinkedID&descrGroupMask == 0 {
+			ids = append(ids, rd.LinkedID)
+			continue
+		}
+
+		if groupID := rd.LinkedID &^ descrGroupMask; groupID != 0 {
+			for _, other := range byID {
+				if other.GroupID&^descrGroupMask == groupID {
+					ids = append(ids, other.ID)
+				}
+			}
+		}
+	}
+
+	return closure
+}
+
+// Extract writes a new image to rw, containing the data objects in f for which all selector funcs
+// return true, along with any data objects they are linked to, directly or indirectly. If no
+// selector funcs are specified, all data objects are included. If no data objects are selected,
+// an error wrapping ErrObjectNotFound is returned.
+//
+// The global header of f, and the ID, group, link, timestamps, name and metadata of each data
+// object are preserved in the new image, so that signatures in the new image remain valid for any
+// data object group that is included in its entirety.
+//
+// If both f and rw are backed by an *os.File, data objects are copied by the kernel where
+// supported, and on Linux are cloned on filesystems that support reflinks where alignment permits.
+func (f *FileImage) Extract(rw ReadWriter, fns ...DescriptorSelectorFunc) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	ds, err := f.getDescriptors(fns...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if len(ds) == 0 {
+		return fmt.Errorf("%w", ErrObjectNotFound)
+	}
+
+	ids := make([]uint32, 0, len(ds))
+	for _, d := range ds {
+		ids = append(ids, d.ID())
+	}
+
+	closure := f.linkClosure(ids)
+
+	// Data objects are written in the order they appear in f, and retain their descriptor index.
+	var is []int
+	for i, rd := range f.rds {
+		if rd.Used && closure[rd.ID] {
+			is = append(is, i)
+		}
+	}
+
+	slices.SortFunc(is, func(a, b int) int {
+		return cmp.Compare(f.rds[a].Offset, f.rds[b].Offset)
+	})
+
+	e := &FileImage{
+		rw:     rw,
+		h:      f.h,
+		rds:    make([]rawDescriptor, len(f.rds)),
+		minIDs: make(map[uint32]uint32),
+	}
+
+	e.h.Arch = hdrArchUnknown
+	e.h.DescriptorsFree = e.h.DescriptorsTotal
+	e.h.DataSize = 0
+
+	end := e.h.DataOffset
+
+	for _, i := range is {
+		rd := &f.rds[i]
+
+		offset, err := nextAligned(end, inferAlignment(rd.Offset))
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if _, err := rw.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if n, err := copyData(rw, offset, io.NewSectionReader(f.rw, rd.Offset, rd.Size), nil); err != nil {
+			return fmt.Errorf("%w", err)
+		} else if n < rd.Size {
+			return fmt.Errorf("%w", io.ErrUnexpectedEOF)
+		}
+
+		d := &e.rds[i]
+		*d = *rd
+		d.Offset = offset
+		d.SizeWithPadding = offset - end + rd.Size
+
+		e.commitDescriptor(d)
+
+		end = offset + rd.Size
+	}
+
+	if err := rw.Truncate(end); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := e.writeDescriptors(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := e.writeHeader(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
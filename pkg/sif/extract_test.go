@@ -0,0 +1,109 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+)
+
+func TestFileImage_Extract(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		fns     []DescriptorSelectorFunc
+		wantIDs []uint32
+		wantErr error
+	}{
+		{
+			name:    "ErrObjectNotFound",
+			path:    filepath.Join(corpus, "one-group.sif"),
+			fns:     []DescriptorSelectorFunc{WithDataType(DataSBOM)},
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name:    "All",
+			path:    filepath.Join(corpus, "two-groups-signed-dsse.sif"),
+			wantIDs: []uint32{1, 2, 3, 4, 5},
+		},
+		{
+			name:    "Object",
+			path:    filepath.Join(corpus, "one-group.sif"),
+			fns:     []DescriptorSelectorFunc{WithID(1)},
+			wantIDs: []uint32{1},
+		},
+		{
+			name:    "LinkedGroup",
+			path:    filepath.Join(corpus, "two-groups-signed-dsse.sif"),
+			fns:     []DescriptorSelectorFunc{WithID(5)},
+			wantIDs: []uint32{3, 5},
+		},
+		{
+			name:    "LinkedObject",
+			path:    filepath.Join(corpus, "two-groups-signed-legacy.sif"),
+			fns:     []DescriptorSelectorFunc{WithDataType(DataSignature), WithLinkedID(2)},
+			wantIDs: []uint32{2, 4},
+		},
+		{
+			name:    "MultipleSelectors",
+			path:    filepath.Join(corpus, "two-groups-signed-dsse.sif"),
+			fns:     []DescriptorSelectorFunc{WithGroupID(1), WithPartitionType(PartPrimSys)},
+			wantIDs: []uint32{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := LoadContainerFromPath(tt.path, OptLoadWithFlag(os.O_RDONLY))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+
+			var b Buffer
+
+			if got, want := f.Extract(&b, tt.fns...), tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if tt.wantErr == nil {
+				e, err := LoadContainer(&b)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var ids []uint32
+				e.WithDescriptors(func(d Descriptor) bool {
+					ids = append(ids, d.ID())
+					return false
+				})
+
+				if got, want := ids, tt.wantIDs; !slices.Equal(got, want) {
+					t.Errorf("got IDs %v, want %v", got, want)
+				}
+
+				if err := e.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"io"
+	"os"
+)
+
+// segment describes a region of a file that either contains data, or is a hole.
+type segment struct {
+	off  int64
+	n    int64
+	hole bool
+}
+
+// clearRange ensures n bytes of fp at offset off read as zero bytes. A hole is punched where
+// supported, so that the region is deallocated. Otherwise, the region is overwritten with zero
+// bytes.
+func clearRange(fp *os.File, off, n int64) error {
+	if ok, err := punchHole(fp, off, n); ok || err != nil {
+		return err
+	}
+
+	if _, err := fp.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := io.CopyN(fp, zeroReader{}, n)
+	return err
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// punchHole deallocates n bytes of fp at offset off using fallocate(2), without changing the size
+// of fp. The region subsequently reads as zero bytes. If punching holes is not supported by the
+// filesystem, false is returned, and fp is not modified.
+func punchHole(fp *os.File, off, n int64) (bool, error) {
+	rc, err := fp.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+
+	var punchErr error
+
+	if err := rc.Control(func(fd uintptr) {
+		for {
+			punchErr = unix.Fallocate(int(fd), //nolint:gosec // File descriptors fit in an int.
+				unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, off, n)
+			if !errors.Is(punchErr, unix.EINTR) {
+				return
+			}
+		}
+	}); err != nil {
+		return false, err
+	}
+
+	if errors.Is(punchErr, unix.EOPNOTSUPP) || errors.Is(punchErr, unix.ENOSYS) {
+		return false, nil
+	}
+
+	return punchErr == nil, punchErr
+}
+
+// reopen returns a new file opened for reading via the file descriptor of fp, which has a file
+// offset independent of that of fp.
+func reopen(fp *os.File) (*os.File, error) {
+	rc, err := fp.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var rfp *os.File
+	var openErr error
+
+	if err := rc.Control(func(fd uintptr) {
+		rfp, openErr = os.Open("/proc/self/fd/" + strconv.FormatUint(uint64(fd), 10))
+	}); err != nil {
+		return nil, err
+	}
+
+	return rfp, openErr
+}
+
+// fileSegments returns the data and hole segments of the region of fp beginning at off and
+// extending for n bytes, or to the end of fp. If holes cannot be located, the region is returned
+// as a single data segment.
+//
+// Holes are located using a separately opened file, so the file offset of fp is not modified, and
+// fp may be in concurrent use by other goroutines.
+func fileSegments(fp *os.File, off, n int64) ([]segment, error) {
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	end := min(off+n, fi.Size())
+
+	rfp, err := reopen(fp)
+	if err != nil {
+		// Holes cannot be located without modifying the file offset of fp.
+		if end > off {
+			return []segment{{off: off, n: end - off}}, nil
+		}
+		return nil, nil
+	}
+	defer rfp.Close()
+
+	var segs []segment
+
+	for pos := off; pos < end; {
+		data, err := rfp.Seek(pos, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// No data remains in the file.
+			data = end
+		} else if errors.Is(err, unix.EINVAL) && len(segs) == 0 {
+			// SEEK_DATA is not supported.
+			return []segment{{off: off, n: end - off}}, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if data = min(data, end); data > pos {
+			segs = append(segs, segment{off: pos, n: data - pos, hole: true})
+		}
+
+		if data == end {
+			break
+		}
+
+		hole, err := rfp.Seek(data, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, err
+		}
+
+		hole = min(hole, end)
+		segs = append(segs, segment{off: data, n: hole - data})
+		pos = hole
+	}
+
+	return segs, nil
+}
@@ -0,0 +1,440 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+const (
+	sparseSize     = 4 << 20 // Size of sparse data object.
+	sparseDataOff  = 1 << 20 // Offset of data within sparse data object.
+	sparseDataSize = 64 << 10
+)
+
+// allocated returns the number of bytes allocated to the file at path.
+func allocated(t *testing.T, path string) int64 {
+	t.Helper()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Fatal("unexpected stat type")
+	}
+
+	return st.Blocks * 512
+}
+
+// requireHoles skips the test if the filesystem containing dir does not support punching holes.
+func requireHoles(t *testing.T, dir string) {
+	t.Helper()
+
+	fp, err := os.Create(filepath.Join(dir, "probe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	defer fp.Close()
+
+	if _, err := fp.Write(make([]byte, 8192)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := punchHole(fp, 0, 4096); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Skip("punching holes not supported")
+	}
+}
+
+// writeSparseFile writes a sparse file containing data at sparseDataOff, and returns the file
+// opened for reading, along with the expected content.
+func writeSparseFile(t *testing.T) (*os.File, []byte) {
+	t.Helper()
+
+	want := make([]byte, sparseSize)
+	copy(want[sparseDataOff:], bytes.Repeat([]byte{0xfa, 0xce}, sparseDataSize/2))
+
+	path := filepath.Join(t.TempDir(), "sparse")
+
+	fp, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	if err := fp.Truncate(sparseSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fp.WriteAt(want[sparseDataOff:sparseDataOff+sparseDataSize], sparseDataOff); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fp.Close() })
+
+	return fp, want
+}
+
+// sparseInput returns a DescriptorInput that reads from src.
+func sparseInput(t *testing.T, src *os.File) DescriptorInput {
+	t.Helper()
+
+	di, err := NewDescriptorInput(DataGeneric, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return di
+}
+
+// checkSparseImage checks that the data object with id in the image at path contains want, and that
+// the image is not fully allocated.
+func checkSparseImage(t *testing.T, path string, id uint32, want []byte) {
+	t.Helper()
+
+	f, err := LoadContainerFromPath(path, OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	d, err := f.GetDescriptor(WithID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := d.GetData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, want) {
+		t.Error("data mismatch")
+	}
+
+	if got, limit := allocated(t, path), int64(sparseSize/2); got > limit {
+		t.Errorf("got %v bytes allocated, want at most %v", got, limit)
+	}
+}
+
+func TestFileImage_DeleteObjectPunchHole(t *testing.T) {
+	dir := t.TempDir()
+	requireHoles(t, dir)
+
+	path := filepath.Join(dir, "image.sif")
+
+	f, err := CreateContainerAtPath(path,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptors(
+			getDescriptorInput(t, DataGeneric, bytes.Repeat([]byte{0xfe, 0xed}, sparseSize/2)),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	d, err := f.GetDescriptor(WithID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := f.DataOffset() + f.DataSize()
+	before := allocated(t, path)
+
+	if err := f.DeleteObject(1, OptDeletePunchHole(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := allocated(t, path), before-sparseSize/2; got > want {
+		t.Errorf("got %v bytes allocated, want at most %v", got, want)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := int64(len(b)), size; got != want {
+		t.Errorf("got size %v, want %v", got, want)
+	}
+
+	if !bytes.Equal(b[d.Offset():d.Offset()+d.Size()], make([]byte, d.Size())) {
+		t.Error("deleted data not zeroed")
+	}
+}
+
+func TestFileImage_SparseData(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, dir string, src *os.File) (string, uint32)
+	}{
+		{
+			name: "AddObject",
+			fn: func(t *testing.T, dir string, src *os.File) (string, uint32) {
+				path := filepath.Join(dir, "image.sif")
+
+				f, err := CreateContainerAtPath(path,
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(sparseInput(t, src)),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.UnloadContainer(); err != nil {
+					t.Fatal(err)
+				}
+
+				return path, 1
+			},
+		},
+		{
+			name: "CopyObjects",
+			fn: func(t *testing.T, dir string, src *os.File) (string, uint32) {
+				f, err := CreateContainerAtPath(filepath.Join(dir, "src.sif"),
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(sparseInput(t, src)),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.UnloadContainer()
+
+				path := filepath.Join(dir, "dst.sif")
+
+				g, err := CreateContainerAtPath(path, OptCreateDeterministic())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := g.CopyObjects(f, WithID(1)); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := g.UnloadContainer(); err != nil {
+					t.Fatal(err)
+				}
+
+				return path, 1
+			},
+		},
+		{
+			name: "Repack",
+			fn: func(t *testing.T, dir string, src *os.File) (string, uint32) {
+				path := filepath.Join(dir, "image.sif")
+
+				f, err := CreateContainerAtPath(path,
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, bytes.Repeat([]byte{0xfe, 0xed}, 4096)),
+						sparseInput(t, src),
+					),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.DeleteObject(1); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.Repack(); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.UnloadContainer(); err != nil {
+					t.Fatal(err)
+				}
+
+				return path, 2
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			requireHoles(t, dir)
+
+			src, want := writeSparseFile(t)
+
+			path, id := tt.fn(t, dir, src)
+
+			checkSparseImage(t, path, id, want)
+		})
+	}
+}
+
+func TestFileImage_CopyObjectsConcurrentWrite(t *testing.T) {
+	const n = 32
+
+	dir := t.TempDir()
+	requireHoles(t, dir)
+
+	// Write a data object consisting of many data segments and holes, so that locating them
+	// requires many seeks.
+	want := make([]byte, sparseSize)
+	for off := 0; off < sparseSize; off += 8192 {
+		copy(want[off:off+4096], bytes.Repeat([]byte{0xfa, 0xce}, 2048))
+	}
+
+	f, err := CreateContainerAtPath(filepath.Join(dir, "src.sif"),
+		OptCreateDeterministic(),
+		OptCreateWithDescriptors(getDescriptorInput(t, DataGeneric, want)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	d, err := f.GetDescriptor(WithID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Punch holes in the data object, avoiding the need to write a sparse file.
+	fp, ok := f.rw.(*os.File)
+	if !ok {
+		t.Fatal("unexpected backing storage type")
+	}
+
+	for off := int64(4096); off < sparseSize; off += 8192 {
+		if _, err := punchHole(fp, d.Offset()+off, 4096); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := CreateContainerAtPath(filepath.Join(dir, "dst.sif"), OptCreateDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.UnloadContainer()
+
+	data := bytes.Repeat([]byte{0xfe, 0xed}, 2048)
+
+	var wg sync.WaitGroup
+
+	// Locating holes in the source image must not disturb concurrent writes to it.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for range n {
+			if _, err := g.CopyObjects(f, WithID(1), OptCopyWithDescriptorGrowth(1)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for range n {
+			di := getDescriptorInput(t, DataGeneric, data)
+			if err := f.AddObject(di, OptAddWithDescriptorGrowth(1)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	for _, h := range []*FileImage{f, g} {
+		if fs, err := h.Check(); err != nil {
+			t.Fatal(err)
+		} else if len(fs) > 0 {
+			t.Errorf("got findings %v", fs)
+		}
+	}
+
+	ds, err := f.GetDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range ds {
+		b, err := d.GetData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.ID() == 1 {
+			if !bytes.Equal(b, want) {
+				t.Errorf("object %v: data mismatch", d.ID())
+			}
+		} else if !bytes.Equal(b, data) {
+			t.Errorf("object %v: data mismatch", d.ID())
+		}
+	}
+
+	ds, err = g.GetDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range ds {
+		if b, err := d.GetData(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(b, want) {
+			t.Errorf("object %v: data mismatch", d.ID())
+		}
+	}
+}
+
+func Test_fileSegments(t *testing.T) {
+	requireHoles(t, t.TempDir())
+
+	fp, _ := writeSparseFile(t)
+
+	const pos = 12345
+
+	if _, err := fp.Seek(pos, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	segs, err := fileSegments(fp, 0, sparseSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []segment{
+		{off: 0, n: sparseDataOff, hole: true},
+		{off: sparseDataOff, n: sparseDataSize},
+		{off: sparseDataOff + sparseDataSize, n: sparseSize - sparseDataOff - sparseDataSize, hole: true},
+	}
+
+	if !slices.Equal(segs, want) {
+		t.Errorf("got segments %v, want %v", segs, want)
+	}
+
+	// The file offset must not be modified.
+	if got, err := fp.Seek(0, io.SeekCurrent); err != nil {
+		t.Fatal(err)
+	} else if got != pos {
+		t.Errorf("got offset %v, want %v", got, pos)
+	}
+}
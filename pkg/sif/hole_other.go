@@ -0,0 +1,33 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !linux
+
+package sif
+
+import "os"
+
+// punchHole is not supported on platforms other than Linux, and always returns false.
+func punchHole(*os.File, int64, int64) (bool, error) {
+	return false, nil
+}
+
+// fileSegments returns the region of fp beginning at off and extending for n bytes, or to the end
+// of fp, as a single data segment, since holes are not located on platforms other than Linux.
+func fileSegments(fp *os.File, off, n int64) ([]segment, error) {
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if end := min(off+n, fi.Size()); end > off {
+		return []segment{{off: off, n: end - off}}, nil
+	}
+
+	return nil, nil
+}
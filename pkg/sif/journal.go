@@ -0,0 +1,185 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// journalMagic identifies a journal trailer.
+var journalMagic = [8]byte{'S', 'I', 'F', '_', 'J', 'R', 'N', 'L'}
+
+// journalTrailer is located at the end of an image with an outstanding journal. The journal
+// contains the global header followed by the descriptors, and immediately precedes the trailer.
+type journalTrailer struct {
+	Magic    [8]byte // Journal magic.
+	Offset   int64   // Offset of journal, which is also the size of the image excluding the journal.
+	Size     int64   // Size of journal.
+	Checksum uint32  // CRC-32C checksum of journal.
+}
+
+// journalTable is the CRC-32C table used to checksum journals.
+var journalTable = crc32.MakeTable(crc32.Castagnoli)
+
+// syncer is implemented by backing storage that supports committing written data to stable
+// storage, such as *os.File.
+type syncer interface {
+	Sync() error
+}
+
+// sync commits data written to the backing storage of f to stable storage, if supported.
+func (f *FileImage) sync() error {
+	if s, ok := f.rw.(syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// marshalMetadata returns the global header of f, followed by the descriptors of f, in the format
+// used by writeHeader and writeDescriptors.
+func (f *FileImage) marshalMetadata() ([]byte, error) {
+	var b bytes.Buffer
+
+	if err := binary.Write(&b, binary.LittleEndian, f.h); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&b, binary.LittleEndian, f.rds); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeJournal appends a journal containing the metadata of f to the backing storage of f at
+// offset, and commits it to stable storage.
+func (f *FileImage) writeJournal(offset int64) error {
+	b, err := f.marshalMetadata()
+	if err != nil {
+		return err
+	}
+
+	jt := journalTrailer{
+		Magic:    journalMagic,
+		Offset:   offset,
+		Size:     int64(len(b)),
+		Checksum: crc32.Checksum(b, journalTable),
+	}
+
+	buf := bytes.NewBuffer(b)
+	if err := binary.Write(buf, binary.LittleEndian, jt); err != nil {
+		return err
+	}
+
+	if _, err := f.rw.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	// The journal and trailer are written together, and the checksum guards against a partially
+	// written journal being replayed.
+	if _, err := f.rw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	return f.sync()
+}
+
+// replayJournal writes the metadata of f to backing storage, and removes the journal located at
+// offset.
+func (f *FileImage) replayJournal(offset int64) error {
+	if err := f.writeDescriptors(); err != nil {
+		return err
+	}
+
+	if err := f.writeHeader(); err != nil {
+		return err
+	}
+
+	// Ensure the metadata is on stable storage before the journal is discarded.
+	if err := f.sync(); err != nil {
+		return err
+	}
+
+	if err := f.rw.Truncate(offset); err != nil {
+		return err
+	}
+
+	return f.sync()
+}
+
+// readJournal returns the trailer of the journal at the end of r, which is of the specified size.
+// If r does not end with a valid journal, false is returned.
+func readJournal(r io.ReaderAt, size int64) (journalTrailer, bool, error) {
+	var jt journalTrailer
+
+	n := int64(binary.Size(jt))
+	if size < n {
+		return jt, false, nil
+	}
+
+	if err := binary.Read(io.NewSectionReader(r, size-n, n), binary.LittleEndian, &jt); err != nil {
+		return jt, false, err
+	}
+
+	if jt.Magic != journalMagic {
+		return jt, false, nil
+	}
+
+	if jt.Offset < 0 || jt.Size < int64(binary.Size(header{})) || jt.Offset != size-n-jt.Size {
+		return jt, false, nil
+	}
+
+	h := crc32.New(journalTable)
+	if _, err := io.Copy(h, io.NewSectionReader(r, jt.Offset, jt.Size)); err != nil {
+		return jt, false, err
+	}
+
+	return jt, h.Sum32() == jt.Checksum, nil
+}
+
+// commit writes the metadata of f to backing storage, unless modifications are being staged by
+// Batch.
+//
+// If f is journaled, the metadata is first appended to the backing storage as a journal, and
+// committed to stable storage, before being written in place. If the write in place is
+// interrupted, the journal is replayed the next time the image is loaded. Otherwise, the metadata
+// is written in place directly, and an interruption may leave the image corrupted.
+func (f *FileImage) commit() error {
+	// Modifications staged by Batch are written once the batch completes.
+	if f.inBatch {
+		return nil
+	}
+
+	if !f.journaled {
+		if err := f.writeDescriptors(); err != nil {
+			return err
+		}
+
+		return f.writeHeader()
+	}
+
+	// Ensure any data objects written are on stable storage before they are referenced.
+	if err := f.sync(); err != nil {
+		return err
+	}
+
+	offset, err := f.size()
+	if err != nil {
+		return err
+	}
+
+	if err := f.writeJournal(offset); err != nil {
+		return err
+	}
+
+	return f.replayJournal(offset)
+}
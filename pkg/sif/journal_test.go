@@ -0,0 +1,275 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultyBuffer is a Buffer that fails all write, truncate and sync operations after the first n.
+// The first failed write is partially applied, to simulate an interrupted write.
+type faultyBuffer struct {
+	*Buffer
+	n int
+}
+
+func (b *faultyBuffer) Write(p []byte) (int, error) {
+	b.n--
+	if b.n == -1 {
+		n, _ := b.Buffer.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	if b.n < 0 {
+		return 0, errInjected
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *faultyBuffer) Truncate(n int64) error {
+	if b.n--; b.n < 0 {
+		return errInjected
+	}
+	return b.Buffer.Truncate(n)
+}
+
+func (b *faultyBuffer) Sync() error {
+	if b.n--; b.n < 0 {
+		return errInjected
+	}
+	return nil
+}
+
+// imageState describes the metadata and data objects of an image.
+type imageState struct {
+	h    header
+	rds  []rawDescriptor
+	data [][]byte
+}
+
+func (s imageState) equal(other imageState) bool {
+	return s.h == other.h &&
+		slices.Equal(s.rds, other.rds) &&
+		slices.EqualFunc(s.data, other.data, bytes.Equal)
+}
+
+// getImageState returns the state of the image contained in b, which is loaded read-only.
+func getImageState(t *testing.T, b []byte) imageState {
+	t.Helper()
+
+	f, err := LoadContainerReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := imageState{
+		h:   f.h,
+		rds: f.rds,
+	}
+
+	ds, err := f.GetDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range ds {
+		b, err := d.GetData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.data = append(s.data, b)
+	}
+
+	return s
+}
+
+func TestFileImage_Journal(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, f *FileImage) error
+	}{
+		{
+			name: "AddObject",
+			fn: func(t *testing.T, f *FileImage) error {
+				return f.AddObject(
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+					OptAddWithDescriptorGrowth(1),
+				)
+			},
+		},
+		{
+			name: "CopyObjects",
+			fn: func(t *testing.T, f *FileImage) error {
+				var b Buffer
+
+				src, err := CreateContainer(&b,
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+					),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				_, err = f.CopyObjects(src, WithID(1), OptCopyWithDescriptorGrowth(1))
+				return err
+			},
+		},
+		{
+			name: "DeleteObject",
+			fn: func(_ *testing.T, f *FileImage) error {
+				return f.DeleteObject(1)
+			},
+		},
+		{
+			name: "DeleteObjectZeroCompact",
+			fn: func(_ *testing.T, f *FileImage) error {
+				return f.DeleteObject(3, OptDeleteZero(true), OptDeleteCompact(true))
+			},
+		},
+		{
+			name: "ReplaceObject",
+			fn: func(_ *testing.T, f *FileImage) error {
+				_, err := f.ReplaceObject(1, bytes.NewReader([]byte{0xfe, 0xed}))
+				return err
+			},
+		},
+		{
+			name: "ReplaceObjectLast",
+			fn: func(_ *testing.T, f *FileImage) error {
+				_, err := f.ReplaceObject(3, bytes.NewReader([]byte{0xfe}))
+				return err
+			},
+		},
+		{
+			name: "SetName",
+			fn: func(_ *testing.T, f *FileImage) error {
+				return f.SetName(1, "name")
+			},
+		},
+		{
+			name: "SetPrimPart",
+			fn: func(_ *testing.T, f *FileImage) error {
+				return f.SetPrimPart(2)
+			},
+		},
+		{
+			name: "SetLaunchScript",
+			fn: func(_ *testing.T, f *FileImage) error {
+				return f.SetLaunchScript("#!/bin/sh\n")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			_, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(3),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+					getDescriptorInput(t, DataPartition, []byte{0xde, 0xad},
+						OptPartitionMetadata(FsExt3, PartSystem, "386"),
+					),
+					getDescriptorInput(t, DataGeneric, []byte{0xbe, 0xef},
+						OptObjectAlignment(4096),
+					),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := getImageState(t, b.Bytes())
+
+			// Determine the state following an uninterrupted modification.
+			after := func() imageState {
+				b := NewBuffer(bytes.Clone(b.Bytes()))
+
+				f, err := LoadContainer(b, OptLoadWithJournal(true))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := tt.fn(t, f); err != nil {
+					t.Fatal(err)
+				}
+
+				return getImageState(t, b.Bytes())
+			}()
+
+			if before.equal(after) {
+				t.Fatal("modification did not change image state")
+			}
+
+			// Interrupt the modification after each write, truncate and sync operation in turn.
+			for n := 0; ; n++ {
+				fb := &faultyBuffer{Buffer: NewBuffer(bytes.Clone(b.Bytes())), n: n}
+
+				f, err := LoadContainer(fb, OptLoadWithJournal(true))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				opErr := tt.fn(t, f)
+				if opErr != nil && !errors.Is(opErr, errInjected) {
+					t.Fatalf("operation %v: got error %v, want %v", n, opErr, errInjected)
+				}
+
+				// Load the interrupted image read-only, which must not modify it.
+				got := getImageState(t, fb.Bytes())
+
+				if opErr == nil && !got.equal(after) {
+					t.Fatalf("operation %v: image not in modified state", n)
+				}
+
+				if !got.equal(before) && !got.equal(after) {
+					t.Fatalf("operation %v: image in neither original nor modified state", n)
+				}
+
+				// Load the interrupted image read/write, which completes any journaled modification.
+				rb := NewBuffer(bytes.Clone(fb.Bytes()))
+
+				r, err := LoadContainer(rb)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, ok, err := readJournal(rb, rb.Len()); err != nil {
+					t.Fatal(err)
+				} else if ok {
+					t.Fatalf("operation %v: journal not removed", n)
+				}
+
+				if s := getImageState(t, rb.Bytes()); !s.equal(got) {
+					t.Fatalf("operation %v: replayed image state differs", n)
+				}
+
+				if fs, err := r.Check(); err != nil {
+					t.Fatal(err)
+				} else if len(fs) > 0 {
+					t.Fatalf("operation %v: got findings %v", n, fs)
+				}
+
+				if opErr == nil {
+					break
+				}
+			}
+		})
+	}
+}
@@ -16,14 +16,65 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"time"
 )
 
 var (
 	errInvalidMagic        = errors.New("invalid SIF magic")
 	errIncompatibleVersion = errors.New("incompatible SIF version")
+	errInvalidLimit        = errors.New("limit must not be negative")
 )
 
+// ErrMalformedImage is the error returned when an image is structurally invalid, such that it
+// cannot be loaded safely.
+var ErrMalformedImage = errors.New("malformed image")
+
+// Limit identifies a resource limit applied to an image.
+type Limit uint8
+
+// List of supported resource limits.
+const (
+	LimitDescriptors     Limit = iota + 1 // Number of descriptors
+	LimitDescriptorsSize                  // Size of descriptor section
+	LimitObjectSize                       // Size of data object read into memory
+)
+
+// String returns a human-readable representation of l.
+func (l Limit) String() string {
+	switch l {
+	case LimitDescriptors:
+		return "descriptor count"
+	case LimitDescriptorsSize:
+		return "descriptor section size"
+	case LimitObjectSize:
+		return "object size"
+	}
+	return "unknown"
+}
+
+// LimitError records an error caused by a resource limit being exceeded.
+type LimitError struct {
+	Limit Limit // Limit that was exceeded.
+	Value int64 // Value that exceeded the limit.
+	Max   int64 // Maximum value permitted by the limit.
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v %v exceeds limit of %v", e.Limit, e.Value, e.Max)
+}
+
+// Is compares e against target. If target is a LimitError and matches e or target has a zero
+// value Limit, true is returned.
+func (e *LimitError) Is(target error) bool {
+	t, ok := target.(*LimitError)
+	if !ok {
+		return false
+	}
+	return e.Limit == t.Limit || t.Limit == 0
+}
+
 // isValidSif looks at key fields from the global header to assess SIF validity.
 func isValidSif(f *FileImage) error {
 	if f.h.Magic != hdrMagic {
@@ -37,24 +88,102 @@ func isValidSif(f *FileImage) error {
 	return nil
 }
 
+// validateHeader validates the global header of f, which is backed by an image of the specified
+// size, according to lo.
+func (f *FileImage) validateHeader(size int64, lo loadOpts) error {
+	h := f.h
+
+	// The descriptors free field is not validated, as it is derived from the descriptors, and is
+	// recomputed by Repair.
+	if h.DescriptorsTotal < 0 {
+		return fmt.Errorf("%w: invalid descriptor count (%v total)", ErrMalformedImage, h.DescriptorsTotal)
+	}
+
+	if h.DescriptorsOffset < 0 || h.DescriptorsSize < 0 || h.DataOffset < 0 || h.DataSize < 0 {
+		return fmt.Errorf("%w: negative offset or size in global header", ErrMalformedImage)
+	}
+
+	if lo.maxDescriptors > 0 && h.DescriptorsTotal > lo.maxDescriptors {
+		return &LimitError{LimitDescriptors, h.DescriptorsTotal, lo.maxDescriptors}
+	}
+
+	if lo.maxDescriptorsSize > 0 && h.DescriptorsSize > lo.maxDescriptorsSize {
+		return &LimitError{LimitDescriptorsSize, h.DescriptorsSize, lo.maxDescriptorsSize}
+	}
+
+	if h.DescriptorsTotal > h.DescriptorsSize/int64(binary.Size(rawDescriptor{})) {
+		return fmt.Errorf("%w: descriptor section too small for %v descriptors",
+			ErrMalformedImage, h.DescriptorsTotal,
+		)
+	}
+
+	if h.DescriptorsOffset > size || h.DescriptorsSize > size-h.DescriptorsOffset {
+		return fmt.Errorf("%w: descriptor section extends past end of image", ErrMalformedImage)
+	}
+
+	return nil
+}
+
+// validateDescriptors validates the descriptors of f.
+func (f *FileImage) validateDescriptors() error {
+	for _, rd := range f.rds {
+		if !rd.Used {
+			continue
+		}
+
+		if rd.Offset < 0 || rd.Size < 0 || rd.SizeWithPadding < 0 || rd.Size > math.MaxInt64-rd.Offset {
+			return fmt.Errorf("%w: object %v has invalid offset or size", ErrMalformedImage, rd.ID)
+		}
+	}
+
+	return nil
+}
+
 // populateMinIDs populates the minIDs field of f.
 func (f *FileImage) populateMinIDs() {
 	f.minIDs = make(map[uint32]uint32)
-	f.WithDescriptors(func(d Descriptor) bool {
-		if minID, ok := f.minIDs[d.raw.GroupID]; !ok || d.ID() < minID {
-			f.minIDs[d.raw.GroupID] = d.ID()
+	for _, rd := range f.rds {
+		if !rd.Used {
+			continue
 		}
-		return false
-	})
+
+		if minID, ok := f.minIDs[rd.GroupID]; !ok || rd.ID < minID {
+			f.minIDs[rd.GroupID] = rd.ID
+		}
+	}
 }
 
-// loadContainer loads a SIF image from rw.
-func loadContainer(rw ReadWriter) (*FileImage, error) {
-	f := FileImage{rw: rw}
+// loadContainer loads a SIF image from rw, according to lo.
+func loadContainer(rw ReadWriter, lo loadOpts) (*FileImage, error) {
+	f := FileImage{
+		rw:            rw,
+		readOnly:      lo.readOnly,
+		journaled:     lo.journaled,
+		maxObjectSize: lo.maxObjectSize,
+	}
+
+	size, err := f.size()
+	if err != nil {
+		return nil, fmt.Errorf("determining image size: %w", err)
+	}
+
+	// If a modification of the image was interrupted, a journal containing the updated metadata
+	// may be present at the end of the image. If so, read the metadata from the journal.
+	jt, journal, err := readJournal(rw, size)
+	if err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+
+	var hdrOffset int64
+	if journal {
+		hdrOffset = jt.Offset
+		size = jt.Offset
+	}
 
 	// Read global header.
-	err := binary.Read(
-		io.NewSectionReader(rw, 0, int64(binary.Size(f.h))),
+	hdrSize := int64(binary.Size(f.h))
+	err = binary.Read(
+		io.NewSectionReader(rw, hdrOffset, hdrSize),
 		binary.LittleEndian,
 		&f.h,
 	)
@@ -66,17 +195,33 @@ func loadContainer(rw ReadWriter) (*FileImage, error) {
 		return nil, err
 	}
 
+	if err := f.validateHeader(size, lo); err != nil {
+		return nil, err
+	}
+
 	// Read descriptors.
+	descrReader := io.NewSectionReader(rw, f.h.DescriptorsOffset, f.h.DescriptorsSize)
+	if journal {
+		descrReader = io.NewSectionReader(rw, jt.Offset+hdrSize, jt.Size-hdrSize)
+	}
+
 	f.rds = make([]rawDescriptor, f.h.DescriptorsTotal)
-	err = binary.Read(
-		io.NewSectionReader(rw, f.h.DescriptorsOffset, f.h.DescriptorsSize),
-		binary.LittleEndian,
-		&f.rds,
-	)
+	err = binary.Read(descrReader, binary.LittleEndian, &f.rds)
 	if err != nil {
 		return nil, fmt.Errorf("reading descriptors: %w", err)
 	}
 
+	if err := f.validateDescriptors(); err != nil {
+		return nil, err
+	}
+
+	// Complete the interrupted modification, unless the image was loaded read-only.
+	if journal && !f.readOnly {
+		if err := f.replayJournal(jt.Offset); err != nil {
+			return nil, fmt.Errorf("replaying journal: %w", err)
+		}
+	}
+
 	f.populateMinIDs()
 
 	return &f, nil
@@ -84,8 +229,15 @@ func loadContainer(rw ReadWriter) (*FileImage, error) {
 
 // loadOpts accumulates container loading options.
 type loadOpts struct {
-	flag          int
-	closeOnUnload bool
+	flag               int
+	closeOnUnload      bool
+	readOnly           bool
+	journaled          bool
+	lock               lockOpts
+	mmap               bool
+	maxDescriptors     int64
+	maxDescriptorsSize int64
+	maxObjectSize      int64
 }
 
 // LoadOpt are used to specify container loading options.
@@ -108,13 +260,111 @@ func OptLoadWithCloseOnUnload(b bool) LoadOpt {
 	}
 }
 
+// OptLoadWithJournal specifies whether modifications to the image should be journaled. When
+// journaling is enabled, an interrupted modification leaves the image in either its original or
+// modified state. By default, modifications are not journaled.
+func OptLoadWithJournal(b bool) LoadOpt {
+	return func(lo *loadOpts) error {
+		lo.journaled = b
+		return nil
+	}
+}
+
+// OptLoadWithLock specifies whether LoadContainerFromPath should place an advisory lock on the
+// container file until the image is unloaded. If the file is opened without write access, a
+// shared lock is placed. Otherwise, an exclusive lock is placed. By default, the file is not
+// locked, and when locking is enabled, LoadContainerFromPath waits until the lock is acquired.
+func OptLoadWithLock(b bool) LoadOpt {
+	return func(lo *loadOpts) error {
+		lo.lock.lock = b
+		return nil
+	}
+}
+
+// OptLoadWithLockTimeout specifies that LoadContainerFromPath should place an advisory lock on
+// the container file, as with OptLoadWithLock, waiting at most d for the lock to be acquired. If
+// d is zero, LoadContainerFromPath does not wait.
+func OptLoadWithLockTimeout(d time.Duration) LoadOpt {
+	return func(lo *loadOpts) error {
+		if d < 0 {
+			return errInvalidLockTimeout
+		}
+		lo.lock = lockOpts{lock: true, timed: true, timeout: d}
+		return nil
+	}
+}
+
+// OptLoadWithMmap specifies whether LoadContainerFromPath should map the container file into
+// memory. The file must be opened without write access. When the file is mapped, data objects are
+// read directly from memory, and Descriptor.GetData does not copy data objects. By default, the
+// file is not mapped.
+func OptLoadWithMmap(b bool) LoadOpt {
+	return func(lo *loadOpts) error {
+		lo.mmap = b
+		return nil
+	}
+}
+
+// OptLoadWithMaxDescriptors specifies that an image containing more than n descriptors should not
+// be loaded. If n is zero, the number of descriptors is not limited.
+func OptLoadWithMaxDescriptors(n int64) LoadOpt {
+	return func(lo *loadOpts) error {
+		if n < 0 {
+			return errInvalidLimit
+		}
+		lo.maxDescriptors = n
+		return nil
+	}
+}
+
+// OptLoadWithMaxDescriptorsSize specifies that an image with a descriptor section larger than n
+// bytes should not be loaded. If n is zero, the size of the descriptor section is not limited.
+func OptLoadWithMaxDescriptorsSize(n int64) LoadOpt {
+	return func(lo *loadOpts) error {
+		if n < 0 {
+			return errInvalidLimit
+		}
+		lo.maxDescriptorsSize = n
+		return nil
+	}
+}
+
+// OptLoadWithMaxObjectSize specifies that Descriptor.GetData should not read data objects larger
+// than n bytes into memory. If n is zero, the size of data objects read is not limited.
+func OptLoadWithMaxObjectSize(n int64) LoadOpt {
+	return func(lo *loadOpts) error {
+		if n < 0 {
+			return errInvalidLimit
+		}
+		lo.maxObjectSize = n
+		return nil
+	}
+}
+
 // LoadContainerFromPath loads a new SIF container from path, according to opts.
 //
 // On success, a FileImage is returned. The caller must call UnloadContainer to ensure resources
-// are released.
+// are released. If the image is structurally invalid, an error wrapping ErrMalformedImage is
+// returned.
 //
 // By default, the file is opened for read and write access. To change this behavior, consider
-// using OptLoadWithFlag.
+// using OptLoadWithFlag. If the file is opened without write access, methods that modify the image
+// return ErrImageReadOnly.
+//
+// By default, modifications to the image are not journaled. To ensure an interrupted modification
+// leaves the image in either its original or modified state, consider using OptLoadWithJournal.
+// If a journal left by an interrupted modification is found, the modification is completed, or
+// if the file is opened without write access, the image is loaded in its modified state without
+// writing to the file.
+//
+// By default, the file is not locked. To prevent concurrent modification of the image by
+// cooperating processes, consider using OptLoadWithLock or OptLoadWithLockTimeout. If the lock
+// cannot be acquired within the specified timeout, an error wrapping ErrImageLocked is returned.
+// Locking is supported on Unix-like platforms only, and has no effect elsewhere.
+//
+// By default, data objects are read from the file as required. To map a file opened without write
+// access into memory, consider using OptLoadWithMmap. Memory mapping is supported on Unix-like
+// platforms only.
 func LoadContainerFromPath(path string, opts ...LoadOpt) (*FileImage, error) {
 	lo := loadOpts{
 		flag: os.O_RDWR,
@@ -126,18 +376,43 @@ func LoadContainerFromPath(path string, opts ...LoadOpt) (*FileImage, error) {
 		}
 	}
 
+	lo.readOnly = lo.flag&(os.O_WRONLY|os.O_RDWR) == 0
+
+	if lo.mmap && !lo.readOnly {
+		return nil, fmt.Errorf("%w", errMmapWritable)
+	}
+
 	fp, err := os.OpenFile(path, lo.flag, 0)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
 
-	f, err := loadContainer(fp)
-	if err != nil {
+	if err := lockFile(fp, !lo.readOnly, lo.lock); err != nil {
 		fp.Close()
 
 		return nil, fmt.Errorf("%w", err)
 	}
 
+	var rw ReadWriter = fp
+	var c io.Closer = fp
+
+	if lo.mmap {
+		m, err := newMappedFile(fp)
+		if err != nil {
+			fp.Close()
+
+			return nil, fmt.Errorf("%w", err)
+		}
+		rw, c = m, m
+	}
+
+	f, err := loadContainer(rw, lo)
+	if err != nil {
+		c.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
 	f.closeOnUnload = true
 	return f, nil
 }
@@ -146,7 +421,12 @@ func LoadContainerFromPath(path string, opts ...LoadOpt) (*FileImage, error) {
 //
 // On success, a FileImage is returned. The caller must call UnloadContainer to ensure resources
 // are released. By default, UnloadContainer will close rw if it implements the io.Closer
-// interface. To change this behavior, consider using OptLoadWithCloseOnUnload.
+// interface. To change this behavior, consider using OptLoadWithCloseOnUnload. If the image is
+// structurally invalid, an error wrapping ErrMalformedImage is returned.
+//
+// By default, modifications to the image are not journaled. To ensure an interrupted modification
+// leaves the image in either its original or modified state, consider using OptLoadWithJournal.
+// If a journal left by an interrupted modification is found, the modification is completed.
 func LoadContainer(rw ReadWriter, opts ...LoadOpt) (*FileImage, error) {
 	lo := loadOpts{
 		closeOnUnload: true,
@@ -158,7 +438,65 @@ func LoadContainer(rw ReadWriter, opts ...LoadOpt) (*FileImage, error) {
 		}
 	}
 
-	f, err := loadContainer(rw)
+	f, err := loadContainer(rw, lo)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	f.closeOnUnload = lo.closeOnUnload
+	return f, nil
+}
+
+// ErrImageReadOnly is the error returned when attempting to modify an image that was loaded
+// read-only.
+var ErrImageReadOnly = errors.New("image is read-only")
+
+// readOnlyReadWriter is a ReadWriter that provides read-only access to the underlying ReaderAt.
+type readOnlyReadWriter struct {
+	*io.SectionReader
+}
+
+// Write always returns ErrImageReadOnly.
+func (readOnlyReadWriter) Write([]byte) (int, error) { return 0, ErrImageReadOnly }
+
+// Truncate always returns ErrImageReadOnly.
+func (readOnlyReadWriter) Truncate(int64) error { return ErrImageReadOnly }
+
+// Close closes the underlying ReaderAt, if it implements the io.Closer interface.
+func (rw readOnlyReadWriter) Close() error {
+	r, _, _ := rw.Outer()
+	if c, ok := r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// LoadContainerReader loads a new SIF container from the first size bytes of r, according to
+// opts. The returned FileImage is read-only, and methods that modify the image return
+// ErrImageReadOnly.
+//
+// On success, a FileImage is returned. The caller must call UnloadContainer to ensure resources
+// are released. By default, UnloadContainer will close r if it implements the io.Closer
+// interface. To change this behavior, consider using OptLoadWithCloseOnUnload. If the image is
+// structurally invalid, an error wrapping ErrMalformedImage is returned.
+//
+// When loading images from untrusted sources, consider bounding the resources consumed using
+// OptLoadWithMaxDescriptors, OptLoadWithMaxDescriptorsSize and OptLoadWithMaxObjectSize. If a
+// limit is exceeded, an error wrapping a LimitError is returned.
+func LoadContainerReader(r io.ReaderAt, size int64, opts ...LoadOpt) (*FileImage, error) {
+	lo := loadOpts{
+		closeOnUnload: true,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&lo); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	lo.readOnly = true
+
+	f, err := loadContainer(readOnlyReadWriter{io.NewSectionReader(r, 0, size)}, lo)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
@@ -167,8 +505,19 @@ func LoadContainer(rw ReadWriter, opts ...LoadOpt) (*FileImage, error) {
 	return f, nil
 }
 
+// checkWritable returns ErrImageReadOnly if f was loaded read-only.
+func (f *FileImage) checkWritable() error {
+	if f.readOnly {
+		return ErrImageReadOnly
+	}
+	return nil
+}
+
 // UnloadContainer unloads f, releasing associated resources.
 func (f *FileImage) UnloadContainer() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	if c, ok := f.rw.(io.Closer); ok && f.closeOnUnload {
 		if err := c.Close(); err != nil {
 			return fmt.Errorf("%w", err)
@@ -10,8 +10,13 @@
 package sif
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -87,6 +92,176 @@ func TestLoadContainer(t *testing.T) {
 	}
 }
 
+// closeRecorder is an io.ReaderAt that records the number of times it has been closed.
+type closeRecorder struct {
+	*bytes.Reader
+	closed int
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed++
+	return nil
+}
+
+func TestLoadContainerReader(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []LoadOpt
+		wantClosed int
+	}{
+		{
+			name:       "NoOpts",
+			wantClosed: 1,
+		},
+		{
+			name:       "CloseOnUnload",
+			opts:       []LoadOpt{OptLoadWithCloseOnUnload(true)},
+			wantClosed: 1,
+		},
+		{
+			name:       "NoCloseOnUnload",
+			opts:       []LoadOpt{OptLoadWithCloseOnUnload(false)},
+			wantClosed: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join(corpus, "one-group.sif"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &closeRecorder{Reader: bytes.NewReader(b)}
+
+			f, err := LoadContainerReader(r, int64(len(b)), tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to load container: %v", err)
+			}
+
+			if got, want := f.DataOffset()+f.DataSize(), int64(len(b)); got != want {
+				t.Errorf("got image size %v, want %v", got, want)
+			}
+
+			if got := r.closed; got != 0 {
+				t.Errorf("got %v closes before unload, want 0", got)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Errorf("failed to unload container: %v", err)
+			}
+
+			if got, want := r.closed, tt.wantClosed; got != want {
+				t.Errorf("got %v closes, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadContainerReadOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(*FileImage) error
+	}{
+		{
+			name: "AddObject",
+			fn: func(f *FileImage) error {
+				di, err := NewDescriptorInput(DataGeneric, bytes.NewReader([]byte{0xfa, 0xce}))
+				if err != nil {
+					return err
+				}
+				return f.AddObject(di)
+			},
+		},
+		{
+			name: "DeleteObject",
+			fn: func(f *FileImage) error {
+				return f.DeleteObject(1)
+			},
+		},
+		{
+			name: "DeleteObjects",
+			fn: func(f *FileImage) error {
+				return f.DeleteObjects(WithGroupID(1))
+			},
+		},
+		{
+			name: "SetPrimPart",
+			fn: func(f *FileImage) error {
+				return f.SetPrimPart(2)
+			},
+		},
+		{
+			name: "SetMetadata",
+			fn: func(f *FileImage) error {
+				return f.SetMetadata(1, newOCIBlobDigest())
+			},
+		},
+		{
+			name: "Repack",
+			fn: func(f *FileImage) error {
+				return f.Repack()
+			},
+		},
+		{
+			name: "Repair",
+			fn: func(f *FileImage) error {
+				_, err := f.Repair()
+				return err
+			},
+		},
+		{
+			name: "Batch",
+			fn: func(f *FileImage) error {
+				return f.Batch(func(tx *Tx) error {
+					return tx.DeleteObject(1)
+				})
+			},
+		},
+	}
+
+	b, err := os.ReadFile(filepath.Join(corpus, "one-group.sif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("Reader", func(t *testing.T) {
+				f, err := LoadContainerReader(bytes.NewReader(b), int64(len(b)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := tt.fn(f), ErrImageReadOnly; !errors.Is(got, want) {
+					t.Errorf("got error %v, want %v", got, want)
+				}
+
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+
+			t.Run("Path", func(t *testing.T) {
+				f, err := LoadContainerFromPath(
+					filepath.Join(corpus, "one-group.sif"),
+					OptLoadWithFlag(os.O_RDONLY),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := tt.fn(f), ErrImageReadOnly; !errors.Is(got, want) {
+					t.Errorf("got error %v, want %v", got, want)
+				}
+
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+		})
+	}
+}
+
 func TestLoadContainerFpMock(t *testing.T) {
 	// This test is using mockSifReadWriter to verify that the code
 	// is not making assumptions regading the behavior of the
@@ -136,3 +311,221 @@ func TestLoadContainerInvalidMagic(t *testing.T) {
 		t.Errorf(`LoadContainerFp(fp, true) did not report an error for a container with invalid magic.`)
 	}
 }
+
+func TestLoadContainerMalformed(t *testing.T) {
+	descrSize := int64(binary.Size(rawDescriptor{}))
+
+	tests := []struct {
+		name    string
+		modify  func(h *header, rds []rawDescriptor)
+		opts    []LoadOpt
+		wantErr error
+	}{
+		{
+			name:   "Valid",
+			modify: func(*header, []rawDescriptor) {},
+		},
+		{
+			name: "NegativeDescriptorsTotal",
+			modify: func(h *header, _ []rawDescriptor) {
+				h.DescriptorsTotal = -1
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name: "DescriptorsFreeExceedsTotal",
+			modify: func(h *header, _ []rawDescriptor) {
+				h.DescriptorsFree = h.DescriptorsTotal + 1
+			},
+		},
+		{
+			name: "NegativeDataOffset",
+			modify: func(h *header, _ []rawDescriptor) {
+				h.DataOffset = -1
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name: "DescriptorsSizeTooSmall",
+			modify: func(h *header, _ []rawDescriptor) {
+				h.DescriptorsSize = h.DescriptorsTotal*descrSize - 1
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name: "HugeDescriptorsTotal",
+			modify: func(h *header, _ []rawDescriptor) {
+				h.DescriptorsTotal = math.MaxInt64
+				h.DescriptorsFree = math.MaxInt64
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name: "DescriptorsPastEOF",
+			modify: func(h *header, _ []rawDescriptor) {
+				h.DescriptorsTotal = 1 << 40
+				h.DescriptorsSize = h.DescriptorsTotal * descrSize
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name: "NegativeObjectSize",
+			modify: func(_ *header, rds []rawDescriptor) {
+				rds[0].Size = -1
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name: "ObjectOffsetOverflow",
+			modify: func(_ *header, rds []rawDescriptor) {
+				rds[0].Offset = math.MaxInt64
+			},
+			wantErr: ErrMalformedImage,
+		},
+		{
+			name:    "MaxDescriptors",
+			modify:  func(*header, []rawDescriptor) {},
+			opts:    []LoadOpt{OptLoadWithMaxDescriptors(1)},
+			wantErr: &LimitError{Limit: LimitDescriptors},
+		},
+		{
+			name:    "MaxDescriptorsSize",
+			modify:  func(*header, []rawDescriptor) {},
+			opts:    []LoadOpt{OptLoadWithMaxDescriptorsSize(descrSize)},
+			wantErr: &LimitError{Limit: LimitDescriptorsSize},
+		},
+		{
+			name:    "InvalidLimit",
+			modify:  func(*header, []rawDescriptor) {},
+			opts:    []LoadOpt{OptLoadWithMaxObjectSize(-1)},
+			wantErr: errInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(2),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h, rds := f.h, slices.Clone(f.rds)
+			tt.modify(&h, rds)
+
+			f.rds = rds
+			if err := f.writeDescriptors(); err != nil {
+				t.Fatal(err)
+			}
+
+			f.h = h
+			if err := f.writeHeader(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err = LoadContainerReader(bytes.NewReader(b.Bytes()), b.Len(), tt.opts...)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err == nil {
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDescriptor_GetDataMaxObjectSize(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(corpus, "one-group.sif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadContainerReader(bytes.NewReader(b), int64(len(b)), OptLoadWithMaxObjectSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := f.UnloadContainer(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	d, err := f.GetDescriptor(WithID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.GetData(); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+
+	d, err = f.GetDescriptor(WithID(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.GetData(); !errors.Is(err, &LimitError{Limit: LimitObjectSize}) {
+		t.Errorf("got error %v, want %v", err, &LimitError{Limit: LimitObjectSize})
+	}
+}
+
+func FuzzLoadContainer(f *testing.F) {
+	for _, dis := range [][]DescriptorInput{
+		nil,
+		{
+			getDescriptorInput(f, DataPartition, []byte{0xfa, 0xce},
+				OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+			),
+			getDescriptorInput(f, DataGeneric, []byte{0xfe, 0xed}, OptLinkedID(1)),
+		},
+	} {
+		var b Buffer
+
+		fi, err := CreateContainer(&b,
+			OptCreateDeterministic(),
+			OptCreateWithDescriptorCapacity(4),
+			OptCreateWithDescriptors(dis...),
+		)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		if err := fi.UnloadContainer(); err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(b.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		fi, err := LoadContainerReader(bytes.NewReader(b), int64(len(b)),
+			OptLoadWithMaxDescriptors(1024),
+			OptLoadWithMaxDescriptorsSize(1<<20),
+			OptLoadWithMaxObjectSize(1<<20),
+		)
+		if err != nil {
+			return
+		}
+		defer fi.UnloadContainer()
+
+		if _, err := fi.Check(); err != nil {
+			t.Fatal(err)
+		}
+
+		fi.WithDescriptors(func(d Descriptor) bool {
+			_, _ = d.GetData()
+			_, _ = d.GetIntegrityReader().Read(make([]byte, 1))
+			return false
+		})
+	})
+}
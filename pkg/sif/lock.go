@@ -0,0 +1,65 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrImageLocked is the error returned when an image file cannot be locked, because a conflicting
+// lock is held.
+var ErrImageLocked = errors.New("image is locked")
+
+var errInvalidLockTimeout = errors.New("lock timeout must not be negative")
+
+// lockPollInterval is the interval at which a lock is retried while waiting with a timeout.
+const lockPollInterval = 10 * time.Millisecond
+
+// lockOpts describes how an image file is locked.
+type lockOpts struct {
+	lock    bool          // Lock image file.
+	timed   bool          // Wait at most timeout for lock, rather than indefinitely.
+	timeout time.Duration // Maximum time to wait for lock.
+}
+
+// lockFile locks fp according to lo. If exclusive is true, an exclusive lock is taken. Otherwise,
+// a shared lock is taken. If the lock cannot be acquired within the timeout specified by lo,
+// ErrImageLocked is returned.
+func lockFile(fp *os.File, exclusive bool, lo lockOpts) error {
+	if !lo.lock {
+		return nil
+	}
+
+	if !lo.timed {
+		_, err := tryLock(fp, exclusive, true)
+		return err
+	}
+
+	deadline := time.Now().Add(lo.timeout)
+
+	for {
+		locked, err := tryLock(fp, exclusive, false)
+		if err != nil {
+			return err
+		}
+
+		if locked {
+			return nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return ErrImageLocked
+		}
+
+		time.Sleep(min(wait, lockPollInterval))
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !unix
+
+package sif
+
+import "os"
+
+// tryLock is a no-op on platforms that do not support flock(2), and always reports that the lock
+// was acquired.
+func tryLock(*os.File, bool, bool) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,212 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build unix
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// copyCorpusImage copies the named image from the corpus to a temporary directory, and returns
+// the path of the copy.
+func copyCorpusImage(t *testing.T, name string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(corpus, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadContainerFromPathLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		heldFlag int
+		flag     int
+		wantErr  error
+	}{
+		{
+			name:     "SharedShared",
+			heldFlag: os.O_RDONLY,
+			flag:     os.O_RDONLY,
+		},
+		{
+			name:     "SharedExclusive",
+			heldFlag: os.O_RDONLY,
+			flag:     os.O_RDWR,
+			wantErr:  ErrImageLocked,
+		},
+		{
+			name:     "ExclusiveShared",
+			heldFlag: os.O_RDWR,
+			flag:     os.O_RDONLY,
+			wantErr:  ErrImageLocked,
+		},
+		{
+			name:     "ExclusiveExclusive",
+			heldFlag: os.O_RDWR,
+			flag:     os.O_RDWR,
+			wantErr:  ErrImageLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := copyCorpusImage(t, "one-group.sif")
+
+			held, err := LoadContainerFromPath(path, OptLoadWithFlag(tt.heldFlag), OptLoadWithLock(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				if err := held.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+
+			f, err := LoadContainerFromPath(path, OptLoadWithFlag(tt.flag), OptLoadWithLockTimeout(0))
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err == nil {
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadContainerFromPathLockTimeout(t *testing.T) {
+	path := copyCorpusImage(t, "one-group.sif")
+
+	held, err := LoadContainerFromPath(path, OptLoadWithLock(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	_, err = LoadContainerFromPath(path, OptLoadWithLockTimeout(50*time.Millisecond))
+	if got, want := err, ErrImageLocked; !errors.Is(got, want) {
+		t.Fatalf("got error %v, want %v", got, want)
+	}
+
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("returned after %v, want at least %v", d, 50*time.Millisecond)
+	}
+
+	// Release the lock while waiting.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		if err := held.UnloadContainer(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	f, err := LoadContainerFromPath(path, OptLoadWithLockTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadContainerFromPathLockWait(t *testing.T) {
+	path := copyCorpusImage(t, "one-group.sif")
+
+	held, err := LoadContainerFromPath(path, OptLoadWithLock(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		if err := held.UnloadContainer(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	f, err := LoadContainerFromPath(path, OptLoadWithLock(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateContainerAtPathLock(t *testing.T) {
+	path := copyCorpusImage(t, "one-group.sif")
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := LoadContainerFromPath(path, OptLoadWithFlag(os.O_RDONLY), OptLoadWithLock(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateContainerAtPath(path, OptCreateWithLockTimeout(0)); !errors.Is(err, ErrImageLocked) {
+		t.Fatalf("got error %v, want %v", err, ErrImageLocked)
+	}
+
+	// The locked image must not be modified.
+	if got, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(got) != string(want) {
+		t.Error("locked image modified")
+	}
+
+	if err := held.UnloadContainer(); err != nil {
+		t.Error(err)
+	}
+
+	f, err := CreateContainerAtPath(path, OptCreateWithLockTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOptLockTimeoutInvalid(t *testing.T) {
+	path := copyCorpusImage(t, "one-group.sif")
+
+	if _, err := LoadContainerFromPath(path, OptLoadWithLockTimeout(-1)); !errors.Is(err, errInvalidLockTimeout) {
+		t.Errorf("got error %v, want %v", err, errInvalidLockTimeout)
+	}
+
+	if _, err := CreateContainerAtPath(path, OptCreateWithLockTimeout(-1)); !errors.Is(err, errInvalidLockTimeout) {
+		t.Errorf("got error %v, want %v", err, errInvalidLockTimeout)
+	}
+}
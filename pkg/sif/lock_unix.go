@@ -0,0 +1,55 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build unix
+
+package sif
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock attempts to place an advisory lock on fp using flock(2). If exclusive is true, an
+// exclusive lock is requested. Otherwise, a shared lock is requested. If block is true, tryLock
+// waits until the lock is acquired. Otherwise, tryLock returns false if a conflicting lock is held.
+func tryLock(fp *os.File, exclusive, block bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	if !block {
+		how |= syscall.LOCK_NB
+	}
+
+	rc, err := fp.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+
+	var lockErr error
+
+	if err := rc.Control(func(fd uintptr) {
+		for {
+			lockErr = syscall.Flock(int(fd), how) //nolint:gosec // File descriptors fit in an int.
+			if !errors.Is(lockErr, syscall.EINTR) {
+				return
+			}
+		}
+	}); err != nil {
+		return false, err
+	}
+
+	if errors.Is(lockErr, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+
+	return lockErr == nil, lockErr
+}
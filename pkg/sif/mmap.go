@@ -0,0 +1,125 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+var (
+	errMmapWritable    = errors.New("memory mapping requires read-only access")
+	errMmapTooLarge    = errors.New("file too large to map into memory")
+	errMmapUnsupported = errors.New("memory mapping not supported on this platform")
+)
+
+// mappedFile is a ReadWriter that provides read-only access to a file mapped into memory.
+type mappedFile struct {
+	fp  *os.File
+	b   []byte
+	pos int64
+}
+
+// newMappedFile maps the contents of fp into memory. On success, the returned mappedFile takes
+// ownership of fp.
+func newMappedFile(fp *os.File) (*mappedFile, error) {
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() > math.MaxInt {
+		return nil, errMmapTooLarge
+	}
+
+	m := &mappedFile{fp: fp}
+
+	// A zero-length mapping is invalid, so empty files are not mapped.
+	if fi.Size() > 0 {
+		if m.b, err = mapFile(fp, int(fi.Size())); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (m *mappedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off >= int64(len(m.b)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, m.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write returns ErrImageReadOnly.
+func (*mappedFile) Write([]byte) (int, error) { return 0, ErrImageReadOnly }
+
+// Seek implements the io.Seeker interface.
+func (m *mappedFile) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = m.pos + offset
+	case io.SeekEnd:
+		abs = int64(len(m.b)) + offset
+	default:
+		return 0, errInvalidWhence
+	}
+
+	if abs < 0 {
+		return 0, errNegativePosition
+	}
+
+	m.pos = abs
+	return abs, nil
+}
+
+// Truncate returns ErrImageReadOnly.
+func (*mappedFile) Truncate(int64) error { return ErrImageReadOnly }
+
+// Close unmaps the file from memory, and closes it.
+func (m *mappedFile) Close() error {
+	var err error
+	if m.b != nil {
+		err = unmapFile(m.b)
+		m.b = nil
+	}
+
+	return errors.Join(err, m.fp.Close())
+}
+
+// slicer is implemented by backing storage that provides direct access to its contents.
+type slicer interface {
+	// slice returns the n bytes at off without copying, or false if they are not available.
+	slice(off, n int64) ([]byte, bool)
+}
+
+// slice returns the n bytes of the mapping at off, or false if they lie outside the mapping.
+func (m *mappedFile) slice(off, n int64) ([]byte, bool) {
+	if off < 0 || n < 0 || off > int64(len(m.b)) || n > int64(len(m.b))-off {
+		return nil, false
+	}
+
+	return m.b[off : off+n : off+n], true
+}
@@ -0,0 +1,23 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !unix
+
+package sif
+
+import "os"
+
+// mapFile returns an error on platforms that do not support mmap(2).
+func mapFile(*os.File, int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// unmapFile is a no-op on platforms that do not support mmap(2).
+func unmapFile([]byte) error {
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build unix
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadContainerFromPathMmap(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    int
+		wantErr error
+	}{
+		{
+			name:    "ReadWrite",
+			flag:    os.O_RDWR,
+			wantErr: errMmapWritable,
+		},
+		{
+			name: "ReadOnly",
+			flag: os.O_RDONLY,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(corpus, "one-group-signed-dsse.sif")
+
+			f, err := LoadContainerFromPath(path, OptLoadWithFlag(tt.flag), OptLoadWithMmap(true))
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() {
+				if err := f.UnloadContainer(); err != nil {
+					t.Error(err)
+				}
+			})
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ds, err := f.GetDescriptors()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, d := range ds {
+				b, err := d.GetData()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := b, want[d.Offset():d.Offset()+d.Size()]; !bytes.Equal(got, want) {
+					t.Errorf("object %v: got data %v, want %v", d.ID(), got, want)
+				}
+
+				// Data must not be copied.
+				if c, err := d.GetData(); err != nil {
+					t.Fatal(err)
+				} else if len(b) > 0 && &c[0] != &b[0] {
+					t.Errorf("object %v: data copied", d.ID())
+				}
+
+				r, err := io.ReadAll(d.GetReader())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := r, b; !bytes.Equal(got, want) {
+					t.Errorf("object %v: got reader data %v, want %v", d.ID(), got, want)
+				}
+			}
+
+			if got, want := f.DeleteObject(1), ErrImageReadOnly; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// createBenchmarkImage creates an image containing a single data object of the specified size,
+// and returns its path.
+func createBenchmarkImage(b *testing.B, size int) string {
+	b.Helper()
+
+	path := filepath.Join(b.TempDir(), "image.sif")
+
+	f, err := CreateContainerAtPath(path,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptors(
+			getDescriptorInput(b, DataGeneric, bytes.Repeat([]byte{0xfe}, size)),
+		),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		b.Fatal(err)
+	}
+
+	return path
+}
+
+// benchmarkLoad loads the image at path read-only, according to opts, and returns the descriptor
+// of its data object.
+func benchmarkLoad(b *testing.B, path string, opts ...LoadOpt) Descriptor {
+	b.Helper()
+
+	f, err := LoadContainerFromPath(path, append([]LoadOpt{OptLoadWithFlag(os.O_RDONLY)}, opts...)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Cleanup(func() {
+		if err := f.UnloadContainer(); err != nil {
+			b.Error(err)
+		}
+	})
+
+	d, err := f.GetDescriptor(WithID(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return d
+}
+
+var (
+	benchmarkSizes    = []int{512, 64 << 10, 16 << 20}
+	benchmarkBackends = []struct {
+		name string
+		mmap bool
+	}{
+		{name: "File"},
+		{name: "Mmap", mmap: true},
+	}
+)
+
+func BenchmarkDescriptor_GetData(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		path := createBenchmarkImage(b, size)
+
+		for _, bb := range benchmarkBackends {
+			b.Run(fmt.Sprintf("%v/Size%v", bb.name, size), func(b *testing.B) {
+				d := benchmarkLoad(b, path, OptLoadWithMmap(bb.mmap))
+
+				b.SetBytes(int64(size))
+
+				for b.Loop() {
+					if _, err := d.GetData(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDescriptor_GetReader(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		path := createBenchmarkImage(b, size)
+
+		for _, bb := range benchmarkBackends {
+			b.Run(fmt.Sprintf("%v/Size%v", bb.name, size), func(b *testing.B) {
+				d := benchmarkLoad(b, path, OptLoadWithMmap(bb.mmap))
+
+				b.SetBytes(int64(size))
+
+				for b.Loop() {
+					if _, err := io.Copy(io.Discard, d.GetReader()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
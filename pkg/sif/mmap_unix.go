@@ -0,0 +1,41 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build unix
+
+package sif
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first n bytes of fp into memory, read-only, using mmap(2).
+func mapFile(fp *os.File, n int) ([]byte, error) {
+	rc, err := fp.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	var mapErr error
+
+	if err := rc.Control(func(fd uintptr) {
+		//nolint:gosec // File descriptors fit in an int.
+		b, mapErr = syscall.Mmap(int(fd), 0, n, syscall.PROT_READ, syscall.MAP_SHARED)
+	}); err != nil {
+		return nil, err
+	}
+
+	return b, mapErr
+}
+
+// unmapFile unmaps b, which was returned by mapFile, using munmap(2).
+func unmapFile(b []byte) error {
+	return syscall.Munmap(b)
+}
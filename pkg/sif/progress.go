@@ -0,0 +1,94 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"context"
+	"io"
+)
+
+// ProgressFunc is called to report the progress of an operation that writes data objects. written
+// is the number of bytes of data object content written so far, and total is the number of bytes
+// to be written, or -1 if this is not known in advance.
+type ProgressFunc func(written, total int64)
+
+// progress tracks the data object content written by an operation.
+type progress struct {
+	ctx     context.Context //nolint:containedctx
+	fn      ProgressFunc
+	written int64
+	total   int64
+}
+
+// newProgress returns a progress that checks ctx for cancellation, and reports progress to fn,
+// which may be nil, while writing total bytes of data object content.
+func newProgress(ctx context.Context, fn ProgressFunc, total int64) *progress {
+	return &progress{ctx: ctx, fn: fn, total: total}
+}
+
+// inputSize returns the total size of the data objects described by dis, or -1 if the size of any
+// data object is not known.
+func inputSize(dis []DescriptorInput) int64 {
+	var total int64
+	for _, di := range dis {
+		if di.opts.size < 0 {
+			return -1
+		}
+		total += di.opts.size
+	}
+	return total
+}
+
+// reader returns a reader that reads from r, checking for cancellation before each read, and
+// reporting progress after each read.
+func (p *progress) reader(r io.Reader) io.Reader {
+	// Avoid wrapping r where possible, since wrapping prevents io.Copy from using optimized copies.
+	if p.ctx.Done() == nil && p.fn == nil {
+		return r
+	}
+	return &progressReader{r: r, p: p}
+}
+
+// progressReader is an io.Reader that tracks the data read on behalf of a progress.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+// check returns the error from the context of p, if it is done. p may be nil.
+func (p *progress) check() error {
+	if p == nil {
+		return nil
+	}
+	return p.ctx.Err()
+}
+
+// add records that n bytes have been written, and reports progress. p may be nil.
+func (p *progress) add(n int64) {
+	if p == nil || n == 0 {
+		return
+	}
+
+	p.written += n
+
+	if p.fn != nil {
+		p.fn(p.written, p.total)
+	}
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	if err := pr.p.check(); err != nil {
+		return 0, err
+	}
+
+	n, err := pr.r.Read(b)
+	pr.p.add(int64(n))
+
+	return n, err
+}
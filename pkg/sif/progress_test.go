@@ -0,0 +1,188 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	// Large enough that data objects are written using multiple reads.
+	data := bytes.Repeat([]byte{0xfe}, 100000)
+
+	tests := []struct {
+		name      string
+		fn        func(t *testing.T, b *Buffer, ctx context.Context, fn ProgressFunc) error
+		wantTotal int64
+		wantEmpty bool // Image discarded on cancellation.
+	}{
+		{
+			name: "AddObject",
+			fn: func(t *testing.T, b *Buffer, ctx context.Context, fn ProgressFunc) error {
+				f, err := LoadContainer(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return f.AddObject(getDescriptorInput(t, DataGeneric, data),
+					OptAddWithDescriptorGrowth(1),
+					OptAddWithContext(ctx),
+					OptAddWithProgress(fn),
+				)
+			},
+			wantTotal: int64(len(data)),
+		},
+		{
+			name: "AddObjectUnknownSize",
+			fn: func(t *testing.T, b *Buffer, ctx context.Context, fn ProgressFunc) error {
+				f, err := LoadContainer(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				di, err := NewDescriptorInput(DataGeneric, io.MultiReader(bytes.NewReader(data)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return f.AddObject(di,
+					OptAddWithDescriptorGrowth(1),
+					OptAddWithContext(ctx),
+					OptAddWithProgress(fn),
+				)
+			},
+			wantTotal: -1,
+		},
+		{
+			name: "CopyObjects",
+			fn: func(t *testing.T, b *Buffer, ctx context.Context, fn ProgressFunc) error {
+				f, err := LoadContainer(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				_, err = f.CopyObjects(f, WithID(1),
+					OptCopyWithDescriptorGrowth(1),
+					OptCopyWithContext(ctx),
+					OptCopyWithProgress(fn),
+				)
+				return err
+			},
+			wantTotal: int64(len(data)),
+		},
+		{
+			name: "CreateContainer",
+			fn: func(t *testing.T, b *Buffer, ctx context.Context, fn ProgressFunc) error {
+				if err := b.Truncate(0); err != nil {
+					t.Fatal(err)
+				}
+
+				_, err := CreateContainer(b,
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, data),
+						getDescriptorInput(t, DataGeneric, data),
+					),
+					OptCreateWithContext(ctx),
+					OptCreateWithProgress(fn),
+				)
+				return err
+			},
+			wantTotal: int64(2 * len(data)),
+			wantEmpty: true,
+		},
+		{
+			name: "WriteContainer",
+			fn: func(t *testing.T, _ *Buffer, ctx context.Context, fn ProgressFunc) error {
+				return WriteContainer(io.Discard,
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, data),
+					),
+					OptCreateWithContext(ctx),
+					OptCreateWithProgress(fn),
+				)
+			},
+			wantTotal: int64(len(data)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			_, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptorCapacity(1),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, data),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original := bytes.Clone(b.Bytes())
+
+			t.Run("Complete", func(t *testing.T) {
+				b := NewBuffer(bytes.Clone(original))
+
+				var written int64
+
+				err := tt.fn(t, b, context.Background(), func(n, total int64) {
+					if n <= written {
+						t.Errorf("got written %v, want more than %v", n, written)
+					}
+					written = n
+
+					if got, want := total, tt.wantTotal; got != want {
+						t.Errorf("got total %v, want %v", got, want)
+					}
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := tt.wantTotal
+				if want < 0 {
+					want = int64(len(data))
+				}
+
+				if got := written; got != want {
+					t.Errorf("got written %v, want %v", got, want)
+				}
+			})
+
+			t.Run("Cancelled", func(t *testing.T) {
+				b := NewBuffer(bytes.Clone(original))
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				// Cancel once writing has started.
+				err := tt.fn(t, b, ctx, func(int64, int64) { cancel() })
+				if got, want := err, context.Canceled; !errors.Is(got, want) {
+					t.Fatalf("got error %v, want %v", got, want)
+				}
+
+				if tt.wantEmpty {
+					if got := b.Len(); got != 0 {
+						t.Errorf("got %v bytes, want image discarded", got)
+					}
+				} else if !bytes.Equal(b.Bytes(), original) {
+					t.Error("image modified")
+				}
+			})
+		})
+	}
+}
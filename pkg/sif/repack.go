@@ -0,0 +1,126 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// repackOpts accumulates repack options.
+type repackOpts struct {
+	t time.Time
+}
+
+// RepackOpt are used to specify repack options.
+type RepackOpt func(*repackOpts) error
+
+// OptRepackDeterministic sets header/descriptor fields to values that support deterministic
+// modification of images.
+func OptRepackDeterministic() RepackOpt {
+	return func(ro *repackOpts) error {
+		ro.t = time.Time{}
+		return nil
+	}
+}
+
+// OptRepackWithTime specifies t as the image modification time.
+func OptRepackWithTime(t time.Time) RepackOpt {
+	return func(ro *repackOpts) error {
+		ro.t = t
+		return nil
+	}
+}
+
+// Repack defragments the data section of f, according to opts. Data objects are moved towards the
+// start of the data section to eliminate unused space between them, and the image is truncated to
+// remove unused space at the end of the data section.
+//
+// The alignment of each data object is retained. Since the original alignment requirement of a
+// data object is not recorded in the image, it is inferred from the current offset of the object,
+// up to a maximum of 4096 bytes.
+//
+// Only the offsets of data objects are modified, so existing signatures are not invalidated. If the
+// image is backed by a regular file, holes within data objects are preserved where supported.
+//
+// Data objects are moved in place, so Repack is not atomic, even if modifications to the image are
+// journaled. If Repack is interrupted, the image may be corrupted.
+//
+// By default, the image modification time is set to the current time for non-deterministic images,
+// and unset otherwise. To override this, consider using OptRepackDeterministic or
+// OptRepackWithTime.
+func (f *FileImage) Repack(opts ...RepackOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	ro := repackOpts{}
+
+	if !f.isDeterministic() {
+		ro.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ro); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	var rds []*rawDescriptor
+	for i := range f.rds {
+		if rd := &f.rds[i]; rd.Used {
+			rds = append(rds, rd)
+		}
+	}
+
+	slices.SortFunc(rds, func(a, b *rawDescriptor) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	// Slide each data object down to the first suitably aligned offset following the previous
+	// object. Objects are processed in order of offset, so an object is never moved over data that
+	// has not yet been relocated.
+	end := f.h.DataOffset
+
+	for _, rd := range rds {
+		offset, err := nextAligned(end, inferAlignment(rd.Offset))
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if offset < rd.Offset {
+			if err := f.moveData(offset, rd.Offset, rd.Size); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			rd.Offset = offset
+		}
+
+		rd.SizeWithPadding = rd.Offset - end + rd.Size
+		end = rd.Offset + rd.Size
+	}
+
+	f.h.DataSize = end - f.h.DataOffset
+	f.h.ModifiedAt = ro.t.Unix()
+
+	if err := f.commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := f.rw.Truncate(end); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+)
+
+func TestFileImage_Repack(t *testing.T) {
+	tests := []struct {
+		name       string
+		createOpts []CreateOpt
+		deleteIDs  []uint32
+		opts       []RepackOpt
+		wantErr    error
+	}{
+		{
+			name: "Empty",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+			},
+		},
+		{
+			name: "NoGaps",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+				),
+			},
+		},
+		{
+			name: "GapStart",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			deleteIDs: []uint32{1},
+		},
+		{
+			name: "GapMiddle",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			deleteIDs: []uint32{2},
+		},
+		{
+			name: "GapEnd",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			deleteIDs: []uint32{3},
+		},
+		{
+			name: "Aligned",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, bytes.Repeat([]byte{0xfa}, 8192)),
+					getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}, OptObjectAlignment(32)),
+				),
+			},
+			deleteIDs: []uint32{1},
+		},
+		{
+			name: "Deterministic",
+			createOpts: []CreateOpt{
+				OptCreateWithID("de170c43-36ab-44a8-bca9-1ea1a070a274"),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+				),
+				OptCreateWithTime(time.Unix(946702800, 0)),
+			},
+			deleteIDs: []uint32{1},
+			opts: []RepackOpt{
+				OptRepackDeterministic(),
+			},
+		},
+		{
+			name: "WithTime",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+				),
+			},
+			deleteIDs: []uint32{1},
+			opts: []RepackOpt{
+				OptRepackWithTime(time.Unix(946702800, 0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b, tt.createOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, id := range tt.deleteIDs {
+				if err := f.DeleteObject(id, OptDeleteDeterministic()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Record integrity-protected fields and data of remaining objects.
+			want := make(map[uint32][]byte)
+			f.WithDescriptors(func(d Descriptor) bool {
+				want[d.ID()] = getObjectState(t, d)
+				return false
+			})
+
+			if got, want := f.Repack(tt.opts...), tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			f.WithDescriptors(func(d Descriptor) bool {
+				if got := getObjectState(t, d); !bytes.Equal(got, want[d.ID()]) {
+					t.Errorf("object %v: state changed", d.ID())
+				}
+				return false
+			})
+
+			if got, want := b.Len(), f.DataOffset()+f.DataSize(); got != want {
+				t.Errorf("got image size %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+// getObjectState returns the integrity-protected fields and data of the object described by d.
+func getObjectState(t *testing.T, d Descriptor) []byte {
+	t.Helper()
+
+	b, err := io.ReadAll(io.MultiReader(d.GetIntegrityReader(), d.GetReader()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
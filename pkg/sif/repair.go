@@ -0,0 +1,207 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"fmt"
+	"time"
+)
+
+// repairOpts accumulates image repair options.
+type repairOpts struct {
+	t time.Time
+}
+
+// RepairOpt are used to specify image repair options.
+type RepairOpt func(*repairOpts) error
+
+// OptRepairDeterministic sets header/descriptor fields to values that support deterministic
+// modification of images.
+func OptRepairDeterministic() RepairOpt {
+	return func(ro *repairOpts) error {
+		ro.t = time.Time{}
+		return nil
+	}
+}
+
+// OptRepairWithTime specifies t as the image/object modification time.
+func OptRepairWithTime(t time.Time) RepairOpt {
+	return func(ro *repairOpts) error {
+		ro.t = t
+		return nil
+	}
+}
+
+// Fix describes a modification made while repairing an image.
+type Fix struct {
+	ID      uint32 // ID of the data object concerned, or zero if the global header is concerned.
+	Message string // Human-readable description of the modification.
+}
+
+// String returns a human-readable representation of f.
+func (f Fix) String() string {
+	if f.ID == 0 {
+		return f.Message
+	}
+	return fmt.Sprintf("object %v: %v", f.ID, f.Message)
+}
+
+// repairDescriptors removes the descriptors of data objects in f that do not lie within the data
+// section of an image of the specified size, and demotes all but the first primary system
+// partition for each architecture. The modification time of demoted partitions is set to t.
+func (f *FileImage) repairDescriptors(size int64, t time.Time) ([]Fix, error) {
+	var fixes []Fix
+
+	primaryArchs := make(map[archType]bool)
+
+	for i := range f.rds {
+		rd := &f.rds[i]
+		if !rd.Used {
+			continue
+		}
+
+		if rd.Offset < f.h.DataOffset || rd.Size < 0 || rd.Offset+rd.Size > size {
+			fixes = append(fixes, Fix{rd.ID, "removed descriptor of data object outside image"})
+			*rd = rawDescriptor{}
+			continue
+		}
+
+		// Partitions with metadata that cannot be decoded are left unmodified, and are reported by
+		// Check.
+		var p partition
+		if rd.DataType != DataPartition || rd.getExtra(binaryUnmarshaler{&p}) != nil {
+			continue
+		}
+
+		if p.Parttype != PartPrimSys {
+			continue
+		}
+
+		if !primaryArchs[p.Arch] {
+			primaryArchs[p.Arch] = true
+			continue
+		}
+
+		p.Parttype = PartSystem
+
+		if err := rd.setExtra(p); err != nil {
+			return nil, err
+		}
+
+		rd.ModifiedAt = t.Unix()
+
+		fixes = append(fixes, Fix{rd.ID, fmt.Sprintf(
+			"changed to system partition, as a primary system partition exists for architecture %v",
+			p.Arch.GoArch(),
+		)})
+	}
+
+	return fixes, nil
+}
+
+// repairHeader recomputes the fields of the global header of f that are derived from the
+// descriptors of f.
+func (f *FileImage) repairHeader() []Fix {
+	var fixes []Fix
+
+	var free int64
+	for _, rd := range f.rds {
+		if !rd.Used {
+			free++
+		}
+	}
+
+	if free != f.h.DescriptorsFree {
+		fixes = append(fixes, Fix{0, fmt.Sprintf(
+			"changed descriptors free from %v to %v", f.h.DescriptorsFree, free,
+		)})
+		f.h.DescriptorsFree = free
+	}
+
+	if size := f.calculatedDataSize(); size != f.h.DataSize {
+		fixes = append(fixes, Fix{0, fmt.Sprintf(
+			"changed data size from %v to %v", f.h.DataSize, size,
+		)})
+		f.h.DataSize = size
+	}
+
+	if arch := f.primaryArch(); arch != f.h.Arch {
+		fixes = append(fixes, Fix{0, fmt.Sprintf(
+			"changed primary architecture from %v to %v", f.h.Arch.GoArch(), arch.GoArch(),
+		)})
+		f.h.Arch = arch
+	}
+
+	return fixes
+}
+
+// Repair repairs structural inconsistencies in f, according to opts. On success, the
+// modifications made are returned. If f is consistent, no modifications are made, and the
+// returned slice is empty.
+//
+// Descriptors of data objects that do not lie within the image, such as those left behind by an
+// interrupted write or truncated download, are removed. If more than one primary system partition
+// exists for an architecture, all but the first are changed to system partitions. Finally, the
+// descriptors free, data size and primary architecture fields of the global header are
+// recomputed. Note that modifications may invalidate existing signatures.
+//
+// Repair does not attempt to recover from inconsistencies that prevent the image from being
+// loaded, modify the contents of data objects, or modify partition metadata that cannot be
+// decoded. To identify inconsistencies without modifying
+// the image, consider using Check.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptRepairDeterministic or OptRepairWithTime.
+func (f *FileImage) Repair(opts ...RepairOpt) ([]Fix, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	ro := repairOpts{}
+
+	if !f.isDeterministic() {
+		ro.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ro); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	size, err := f.size()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	fixes, err := f.repairDescriptors(size, ro.t)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	fixes = append(fixes, f.repairHeader()...)
+
+	if len(fixes) == 0 {
+		return nil, nil
+	}
+
+	f.populateMinIDs()
+
+	f.h.ModifiedAt = ro.t.Unix()
+
+	if err := f.commit(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return fixes, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+)
+
+func TestFileImage_Repair(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(t *testing.T, f *FileImage)
+		opts      []RepairOpt
+		wantFixes []Fix
+	}{
+		{
+			name:   "Consistent",
+			modify: func(*testing.T, *FileImage) {},
+		},
+		{
+			name: "Truncated",
+			modify: func(t *testing.T, f *FileImage) {
+				if err := f.rw.Truncate(36870); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFixes: []Fix{
+				{3, "removed descriptor of data object outside image"},
+				{0, "changed descriptors free from 45 to 46"},
+				{0, "changed data size from 4696 to 4692"},
+			},
+		},
+		{
+			name: "DuplicatePrimary",
+			modify: func(_ *testing.T, f *FileImage) {
+				f.rds[2].DataType = DataPartition
+				f.rds[2].Extra = f.rds[1].Extra
+			},
+			wantFixes: []Fix{
+				{3, "changed to system partition, as a primary system partition exists for architecture 386"},
+			},
+		},
+		{
+			name: "Header",
+			modify: func(_ *testing.T, f *FileImage) {
+				f.h.DescriptorsFree = 0
+				f.h.DataSize = 0
+				f.h.Arch = hdrArchUnknown
+			},
+			wantFixes: []Fix{
+				{0, "changed descriptors free from 0 to 45"},
+				{0, "changed data size from 0 to 4696"},
+				{0, "changed primary architecture from unknown to 386"},
+			},
+		},
+		{
+			name: "WithTime",
+			modify: func(_ *testing.T, f *FileImage) {
+				f.h.DescriptorsFree = 0
+			},
+			opts: []RepairOpt{
+				OptRepairWithTime(time.Unix(946702800, 0)),
+			},
+			wantFixes: []Fix{
+				{0, "changed descriptors free from 0 to 45"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce, 0xfe, 0xed},
+						OptPartitionMetadata(FsRaw, PartSystem, "386"),
+					),
+					getDescriptorInput(t, DataPartition, []byte{0xde, 0xad, 0xbe, 0xef},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+					getDescriptorInput(t, DataGeneric, []byte{0xba, 0xdd, 0xca, 0xfe}),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.modify(t, f)
+
+			fixes, err := f.Repair(tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := fixes, tt.wantFixes; !slices.Equal(got, want) {
+				t.Errorf("got fixes %v, want %v", got, want)
+			}
+
+			if fs, err := f.Check(); err != nil {
+				t.Fatal(err)
+			} else if len(fs) > 0 {
+				t.Errorf("got findings %v after repair, want none", fs)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_RepairDescriptorsFree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.sif")
+
+	f, err := CreateContainerAtPath(path,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptors(
+			getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the descriptors free field, such that it exceeds the descriptor capacity.
+	f.h.DescriptorsFree = f.h.DescriptorsTotal + 1
+
+	if err := f.writeHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = LoadContainerFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	fixes, err := f.Repair(OptRepairDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := fixes, []Fix{{0, "changed descriptors free from 49 to 47"}}; !slices.Equal(got, want) {
+		t.Errorf("got fixes %v, want %v", got, want)
+	}
+
+	if fs, err := f.Check(); err != nil {
+		t.Fatal(err)
+	} else if len(fs) > 0 {
+		t.Errorf("got findings %v after repair, want none", fs)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// replaceOpts accumulates object replacement options.
+type replaceOpts struct {
+	t time.Time
+}
+
+// ReplaceOpt are used to specify object replacement options.
+type ReplaceOpt func(*replaceOpts) error
+
+// OptReplaceDeterministic sets header/descriptor fields to values that support deterministic
+// modification of images.
+func OptReplaceDeterministic() ReplaceOpt {
+	return func(ro *replaceOpts) error {
+		ro.t = time.Time{}
+		return nil
+	}
+}
+
+// OptReplaceWithTime specifies t as the image/object modification time.
+func OptReplaceWithTime(t time.Time) ReplaceOpt {
+	return func(ro *replaceOpts) error {
+		ro.t = t
+		return nil
+	}
+}
+
+// availableSpace returns the number of bytes available to the data object described by rd before
+// the start of the following data object. If rd describes the last data object in the image, the
+// available space is unlimited.
+func (f *FileImage) availableSpace(rd *rawDescriptor) int64 {
+	next := int64(math.MaxInt64)
+
+	for _, other := range f.rds {
+		if other.Used && other.Offset > rd.Offset && other.Size > 0 {
+			next = min(next, other.Offset)
+		}
+	}
+
+	if next == math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return next - rd.Offset
+}
+
+// replaceData writes the contents of r to the data object described by rd, updating the offset,
+// size and padding of rd. Data is written in place where possible. If the contents of r do not fit
+// in the space available, the data object is relocated to the end of the data section.
+func (f *FileImage) replaceData(rd *rawDescriptor, r io.Reader) error {
+	if _, err := f.rw.Seek(rd.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	// If modifications are journaled, the existing contents must be retained until the updated
+	// descriptor has been written, so the data object is always relocated.
+	available := f.availableSpace(rd)
+	if f.journaled {
+		available = 0
+	}
+
+	n, err := io.CopyN(f.rw, r, available)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	padding := rd.SizeWithPadding - rd.Size
+
+	if n == available {
+		// Determine if there is more data to be written.
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(r, b); errors.Is(err, io.EOF) {
+			b = nil
+		} else if err != nil {
+			return err
+		}
+
+		if len(b) > 0 {
+			// Relocate the data written so far to the end of the data section, retaining the
+			// alignment of the data object, and write the remainder.
+			end := f.h.DataOffset + f.calculatedDataSize()
+
+			offset, err := nextAligned(end, inferAlignment(rd.Offset))
+			if err != nil {
+				return err
+			}
+
+			if err := f.moveData(offset, rd.Offset, n); err != nil {
+				return err
+			}
+
+			m, err := io.Copy(f.rw, io.MultiReader(bytes.NewReader(b), r))
+			if err != nil {
+				return err
+			}
+
+			n += m
+			padding = offset - end
+			rd.Offset = offset
+		}
+	}
+
+	rd.Size = n
+	rd.SizeWithPadding = padding + n
+
+	return nil
+}
+
+// withStaleSignatures returns a selector func that selects signatures that cover the data object
+// described by rd.
+func withStaleSignatures(rd *rawDescriptor) DescriptorSelectorFunc {
+	groupID := rd.GroupID &^ descrGroupMask
+
+	return func(d Descriptor) (bool, error) {
+		if d.DataType() != DataSignature {
+			return false, nil
+		}
+
+		linkedID, isGroup := d.LinkedID()
+		if isGroup {
+			return groupID != 0 && linkedID == groupID, nil
+		}
+		return linkedID == rd.ID, nil
+	}
+}
+
+// ReplaceObject replaces the contents of the data object with the specified id with data read
+// from r, according to opts. If no data object with the specified id exists, an error wrapping
+// ErrObjectNotFound is returned.
+//
+// The ID, group, link and metadata of the data object are retained, and its size and modification
+// time are updated. If the data object is of type DataOCIRootIndex or DataOCIBlob, its digest is
+// recalculated. The data is written in place if it fits within the space occupied by the existing
+// data object, or if the data object is the last in the image. Otherwise, the data object is
+// relocated to the end of the data section, and the space it previously occupied is left unused.
+// If modifications to the image are journaled, the data object is always relocated. Consider using
+// Repack to reclaim unused space.
+//
+// If reading from r fails, the descriptors are left unmodified, and data written beyond the end of
+// the image is truncated. Data already written in place is not restored, so the contents of the
+// data object may be partially replaced. To avoid this, consider using OptLoadWithJournal.
+//
+// Replacing the contents of a data object invalidates any signatures that cover it. On success,
+// the descriptors of such signatures are returned. The caller may wish to delete these and re-sign
+// the image.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptReplaceDeterministic or OptReplaceWithTime.
+func (f *FileImage) ReplaceObject(id uint32, r io.Reader, opts ...ReplaceOpt) ([]Descriptor, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	ro := replaceOpts{}
+
+	if !f.isDeterministic() {
+		ro.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ro); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	rd, err := f.getDescriptor(WithID(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// Recalculate digest for OCI blobs as they are written.
+	var md *ociBlob
+	if rd.DataType == DataOCIRootIndex || rd.DataType == DataOCIBlob {
+		md = newOCIBlobDigest()
+		r = io.TeeReader(r, md.hasher)
+	}
+
+	last := f.availableSpace(rd) == math.MaxInt64
+
+	s, err := f.saveState()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err := f.replaceData(rd, r); err != nil {
+		return nil, fmt.Errorf("%w", f.restoreState(s, err))
+	}
+
+	if md != nil {
+		if err := rd.setExtra(md); err != nil {
+			return nil, fmt.Errorf("%w", f.restoreState(s, err))
+		}
+	}
+
+	rd.ModifiedAt = ro.t.Unix()
+
+	f.h.DataSize = f.calculatedDataSize()
+
+	f.h.ModifiedAt = ro.t.Unix()
+
+	if err := f.commit(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// If this was the last data object, remove any data remaining from the previous contents,
+	// retaining any empty data objects that follow it. This is done once the updated descriptors
+	// have been written, so that an interrupted replacement does not leave descriptors referring
+	// to discarded data.
+	if last {
+		if err := f.rw.Truncate(f.h.DataOffset + f.h.DataSize); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	var stale []Descriptor
+
+	if err := f.withDescriptors(withStaleSignatures(rd), func(d *rawDescriptor) error {
+		stale = append(stale, f.descriptorFromRaw(d))
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return stale, nil
+}
@@ -0,0 +1,430 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/sebdah/goldie/v2"
+)
+
+func TestFileImage_ReplaceObject(t *testing.T) {
+	tests := []struct {
+		name       string
+		createOpts []CreateOpt
+		id         uint32
+		data       []byte
+		opts       []ReplaceOpt
+		wantErr    error
+	}{
+		{
+			name: "ErrObjectNotFound",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			},
+			id:      2,
+			data:    []byte{0xfe, 0xed},
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name: "InPlaceSmaller",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce, 0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			id:   1,
+			data: []byte{0xfe, 0xed},
+		},
+		{
+			name: "InPlaceSameSize",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			id:   1,
+			data: []byte{0xfe, 0xed},
+		},
+		{
+			name: "InPlaceLast",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			id:   2,
+			data: []byte{0xfe, 0xed, 0xfe, 0xed},
+		},
+		{
+			name: "InPlaceLastSmaller",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad, 0xbe, 0xef}),
+				),
+			},
+			id:   2,
+			data: []byte{0xfe, 0xed},
+		},
+		{
+			name: "InPlaceLastSmallerEmptyFollowing",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad, 0xbe, 0xef}),
+					getDescriptorInput(t, DataGeneric, []byte{}),
+				),
+			},
+			id:   2,
+			data: []byte{0xfe, 0xed},
+		},
+		{
+			name: "Relocate",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			id:   1,
+			data: []byte{0xfe, 0xed, 0xfe, 0xed},
+		},
+		{
+			name: "RelocateAligned",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce},
+						OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+					),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			},
+			id:   1,
+			data: bytes.Repeat([]byte{0xfe, 0xed}, 4096),
+		},
+		{
+			name: "OCIBlob",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataOCIBlob, []byte{0xfa, 0xce}),
+				),
+			},
+			id:   1,
+			data: []byte{0xfe, 0xed},
+		},
+		{
+			name: "Deterministic",
+			createOpts: []CreateOpt{
+				OptCreateWithID("de170c43-36ab-44a8-bca9-1ea1a070a274"),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+				OptCreateWithTime(time.Unix(946702800, 0)),
+			},
+			id:   1,
+			data: []byte{0xfe, 0xed},
+			opts: []ReplaceOpt{
+				OptReplaceDeterministic(),
+			},
+		},
+		{
+			name: "WithTime",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+				),
+			},
+			id:   1,
+			data: []byte{0xfe, 0xed},
+			opts: []ReplaceOpt{
+				OptReplaceWithTime(time.Unix(946702800, 0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b, tt.createOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = f.ReplaceObject(tt.id, bytes.NewReader(tt.data), tt.opts...)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if err == nil {
+				d, err := f.GetDescriptor(WithID(tt.id))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, err := d.GetData(); err != nil {
+					t.Fatal(err)
+				} else if want := tt.data; !bytes.Equal(got, want) {
+					t.Errorf("got data %v, want %v", got, want)
+				}
+			}
+
+			if got, want := int64(b.Len()), f.DataOffset()+f.DataSize(); got != want {
+				t.Errorf("got image size %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_ReplaceObjectStale(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		id        uint32
+		wantStale []uint32
+	}{
+		{
+			name: "Unsigned",
+			path: filepath.Join(corpus, "one-group.sif"),
+			id:   1,
+		},
+		{
+			name:      "SignedGroup",
+			path:      filepath.Join(corpus, "one-group-signed-dsse.sif"),
+			id:        1,
+			wantStale: []uint32{3},
+		},
+		{
+			name:      "SignedLegacy",
+			path:      filepath.Join(corpus, "one-group-signed-legacy-all.sif"),
+			id:        2,
+			wantStale: []uint32{4},
+		},
+		{
+			name: "Signature",
+			path: filepath.Join(corpus, "one-group-signed-dsse.sif"),
+			id:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := LoadContainer(NewBuffer(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ds, err := f.ReplaceObject(tt.id, bytes.NewReader([]byte{0xfe, 0xed}), OptReplaceDeterministic())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []uint32
+			for _, d := range ds {
+				got = append(got, d.ID())
+			}
+
+			if want := tt.wantStale; !slices.Equal(got, want) {
+				t.Errorf("got stale signatures %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFileImage_ReplaceObjectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.sif")
+
+	f, err := CreateContainerAtPath(path,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptors(
+			getDescriptorInput(t, DataGeneric, []byte("aaaa")),
+			getDescriptorInput(t, DataGeneric, []byte("bbbb")),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the first data object with data that does not fit in place, so that it is
+	// relocated.
+	data := bytes.Repeat([]byte("x"), 10000)
+
+	if _, err := f.ReplaceObject(1, bytes.NewReader(data), OptReplaceDeterministic()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = LoadContainerFromPath(path, OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	for id, want := range map[uint32][]byte{1: data, 2: []byte("bbbb")} {
+		d, err := f.GetDescriptor(WithID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := d.GetData(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("object %v: data mismatch", id)
+		}
+	}
+
+	if fs, err := f.Check(); err != nil {
+		t.Fatal(err)
+	} else if len(fs) > 0 {
+		t.Errorf("got findings %v", fs)
+	}
+}
+
+func TestFileImage_ReplaceObjectReadError(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		journaled bool
+		id        uint32
+		data      []byte
+	}{
+		{
+			name: "InPlace",
+			id:   1,
+			data: []byte{0xfe, 0xed},
+		},
+		{
+			name: "Relocate",
+			id:   1,
+			data: bytes.Repeat([]byte{0xfe, 0xed}, 4096),
+		},
+		{
+			name:      "Journaled",
+			journaled: true,
+			id:        1,
+			data:      []byte{0xfe, 0xed},
+		},
+		{
+			name:      "JournaledLast",
+			journaled: true,
+			id:        2,
+			data:      []byte{0xfe, 0xed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce, 0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xde, 0xad}),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err = LoadContainer(&b, OptLoadWithJournal(tt.journaled))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before, err := f.GetDescriptors()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			size := int64(b.Len())
+			image := bytes.Clone(b.Bytes())
+
+			r := io.MultiReader(bytes.NewReader(tt.data), iotest.ErrReader(errFailed))
+
+			if _, err := f.ReplaceObject(tt.id, r); !errors.Is(err, errFailed) {
+				t.Fatalf("got error %v, want %v", err, errFailed)
+			}
+
+			// The descriptors must be unmodified, and data written beyond the end of the image
+			// truncated.
+			after, err := f.GetDescriptors()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(after, before) {
+				t.Error("descriptors modified by failed replacement")
+			}
+
+			if got := int64(b.Len()); got != size {
+				t.Errorf("got image size %v, want %v", got, size)
+			}
+
+			// If modifications are journaled, the image must be unmodified.
+			if tt.journaled && !bytes.Equal(b.Bytes(), image) {
+				t.Error("image modified by failed replacement")
+			}
+
+			if fs, err := f.Check(); err != nil {
+				t.Fatal(err)
+			} else if len(fs) > 0 {
+				t.Errorf("got findings %v", fs)
+			}
+		})
+	}
+}
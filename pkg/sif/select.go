@@ -98,6 +98,18 @@ func WithPartitionType(pt PartType) DescriptorSelectorFunc {
 	}
 }
 
+// WithPartitionArch selects descriptors containing a partition with CPU architecture arch. The value
+// of arch should be the architecture as represented by the Go runtime.
+func WithPartitionArch(arch string) DescriptorSelectorFunc {
+	return func(d Descriptor) (bool, error) {
+		if getSIFArch(arch) == hdrArchUnknown {
+			return false, fmt.Errorf("%w: %v", errUnknownArchitcture, arch)
+		}
+		_, _, a, err := d.raw.getPartitionMetadata()
+		return err == nil && a == arch, nil
+	}
+}
+
 // WithOCIBlobDigest selects descriptors that contain a OCI blob with the specified digest.
 func WithOCIBlobDigest(digest v1.Hash) DescriptorSelectorFunc {
 	return func(d Descriptor) (bool, error) {
@@ -114,14 +126,15 @@ func (f *FileImage) descriptorFromRaw(rd *rawDescriptor) Descriptor {
 		raw:        *rd,
 		r:          f.rw,
 		relativeID: rd.ID - f.minIDs[rd.GroupID],
+		maxSize:    f.maxObjectSize,
 	}
 }
 
-// GetDescriptors returns a slice of in-use descriptors for which all selector funcs return true.
-// If the image contains no data objects, an error wrapping ErrNoObjects is returned.
-func (f *FileImage) GetDescriptors(fns ...DescriptorSelectorFunc) ([]Descriptor, error) {
-	if f.DescriptorsFree() == f.DescriptorsTotal() {
-		return nil, fmt.Errorf("%w", ErrNoObjects)
+// getDescriptors returns a slice of in-use descriptors for which all selector funcs return true.
+// If the image contains no data objects, ErrNoObjects is returned.
+func (f *FileImage) getDescriptors(fns ...DescriptorSelectorFunc) ([]Descriptor, error) {
+	if f.h.DescriptorsFree == f.h.DescriptorsTotal {
+		return nil, ErrNoObjects
 	}
 
 	var ds []Descriptor
@@ -130,6 +143,20 @@ func (f *FileImage) GetDescriptors(fns ...DescriptorSelectorFunc) ([]Descriptor,
 		ds = append(ds, f.descriptorFromRaw(d))
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+// GetDescriptors returns a slice of in-use descriptors for which all selector funcs return true.
+// If the image contains no data objects, an error wrapping ErrNoObjects is returned.
+func (f *FileImage) GetDescriptors(fns ...DescriptorSelectorFunc) ([]Descriptor, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	ds, err := f.getDescriptors(fns...)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
@@ -163,13 +190,28 @@ func (f *FileImage) getDescriptor(fns ...DescriptorSelectorFunc) (*rawDescriptor
 // error wrapping ErrObjectNotFound is returned. If multiple descriptors are selected by fns, an
 // error wrapping ErrMultipleObjectsFound is returned.
 func (f *FileImage) GetDescriptor(fns ...DescriptorSelectorFunc) (Descriptor, error) {
-	if f.DescriptorsFree() == f.DescriptorsTotal() {
-		return Descriptor{}, fmt.Errorf("%w", ErrNoObjects)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	d, err := f.descriptor(fns...)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("%w", err)
+	}
+
+	return d, nil
+}
+
+// descriptor returns the in-use descriptor selected by fns. If the image contains no data objects,
+// ErrNoObjects is returned. If no descriptor is selected by fns, ErrObjectNotFound is returned. If
+// multiple descriptors are selected by fns, ErrMultipleObjectsFound is returned.
+func (f *FileImage) descriptor(fns ...DescriptorSelectorFunc) (Descriptor, error) {
+	if f.h.DescriptorsFree == f.h.DescriptorsTotal {
+		return Descriptor{}, ErrNoObjects
 	}
 
 	d, err := f.getDescriptor(fns...)
 	if err != nil {
-		return Descriptor{}, fmt.Errorf("%w", err)
+		return Descriptor{}, err
 	}
 
 	return f.descriptorFromRaw(d), nil
@@ -225,6 +267,9 @@ func abortOnMatch(*rawDescriptor) error { return errAbort }
 
 // WithDescriptors calls fn with each in-use descriptor in f, until fn returns true.
 func (f *FileImage) WithDescriptors(fn func(d Descriptor) bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	selectFn := func(d Descriptor) (bool, error) {
 		return fn(d), nil
 	}
@@ -40,6 +40,11 @@ func TestFileImage_GetDescriptors(t *testing.T) {
 		},
 	}
 
+	p := partition{Fstype: FsSquash, Parttype: PartPrimSys, Arch: hdrArchAMD64}
+	if err := ds[0].setExtra(p); err != nil {
+		t.Fatal(err)
+	}
+
 	f := &FileImage{
 		rds: ds,
 		h: header{
@@ -126,6 +131,26 @@ func TestFileImage_GetDescriptors(t *testing.T) {
 			},
 			wantErr: ErrInvalidGroupID,
 		},
+		{
+			name: "PartitionArch",
+			fns: []DescriptorSelectorFunc{
+				WithPartitionArch("amd64"),
+			},
+			wantIDs: []uint32{1},
+		},
+		{
+			name: "PartitionArchNoMatch",
+			fns: []DescriptorSelectorFunc{
+				WithPartitionArch("arm64"),
+			},
+		},
+		{
+			name: "PartitionArchUnknown",
+			fns: []DescriptorSelectorFunc{
+				WithPartitionArch("cray"),
+			},
+			wantErr: errUnknownArchitcture,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
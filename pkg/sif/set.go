@@ -18,6 +18,7 @@ import (
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
 )
 
 // setOpts accumulates object set options.
@@ -56,6 +57,13 @@ var (
 // non-deterministic images, and unset otherwise. To override this, consider using
 // OptSetDeterministic or OptSetWithTime.
 func (f *FileImage) SetPrimPart(id uint32, opts ...SetOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
 	so := setOpts{}
 
 	if !f.isDeterministic() {
@@ -91,8 +99,11 @@ func (f *FileImage) SetPrimPart(id uint32, opts ...SetOpt) error {
 		return fmt.Errorf("%w", errNotSystem)
 	}
 
-	// If there is currently a primary system partition, update it.
-	if d, err := f.getDescriptor(WithPartitionType(PartPrimSys)); err == nil {
+	// If there is currently a primary system partition for the same architecture, update it.
+	if d, err := f.getDescriptor(
+		WithPartitionType(PartPrimSys),
+		WithPartitionArch(p.Arch.GoArch()),
+	); err == nil {
 		var p partition
 		if err := d.getExtra(binaryUnmarshaler{&p}); err != nil {
 			return fmt.Errorf("%w", err)
@@ -118,14 +129,10 @@ func (f *FileImage) SetPrimPart(id uint32, opts ...SetOpt) error {
 
 	descr.ModifiedAt = so.t.Unix()
 
-	if err := f.writeDescriptors(); err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	f.h.Arch = p.Arch
+	f.h.Arch = f.primaryArch()
 	f.h.ModifiedAt = so.t.Unix()
 
-	if err := f.writeHeader(); err != nil {
+	if err := f.commit(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
@@ -138,6 +145,13 @@ func (f *FileImage) SetPrimPart(id uint32, opts ...SetOpt) error {
 // non-deterministic images, and unset otherwise. To override this, consider using
 // OptSetDeterministic or OptSetWithTime.
 func (f *FileImage) SetMetadata(id uint32, md encoding.BinaryMarshaler, opts ...SetOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
 	so := setOpts{}
 
 	if !f.isDeterministic() {
@@ -161,13 +175,9 @@ func (f *FileImage) SetMetadata(id uint32, md encoding.BinaryMarshaler, opts ...
 
 	rd.ModifiedAt = so.t.Unix()
 
-	if err := f.writeDescriptors(); err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
 	f.h.ModifiedAt = so.t.Unix()
 
-	if err := f.writeHeader(); err != nil {
+	if err := f.commit(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
@@ -180,6 +190,13 @@ func (f *FileImage) SetMetadata(id uint32, md encoding.BinaryMarshaler, opts ...
 // non-deterministic images, and unset otherwise. To override this, consider using
 // OptSetDeterministic or OptSetWithTime.
 func (f *FileImage) SetOCIBlobDigest(id uint32, h v1.Hash, opts ...SetOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
 	rd, err := f.getDescriptor(WithID(id))
 	if err != nil {
 		return fmt.Errorf("%w", err)
@@ -210,15 +227,265 @@ func (f *FileImage) SetOCIBlobDigest(id uint32, h v1.Hash, opts ...SetOpt) error
 
 	rd.ModifiedAt = so.t.Unix()
 
-	if err := f.writeDescriptors(); err != nil {
+	f.h.ModifiedAt = so.t.Unix()
+
+	if err := f.commit(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
+	return nil
+}
+
+// setDescriptor calls fn with the descriptor of the data object with id, and writes the updated
+// descriptor to backing storage, according to opts. fn is called with f.mu held.
+func (f *FileImage) setDescriptor(id uint32, fn func(*rawDescriptor) error, opts ...SetOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return err
+	}
+
+	so := setOpts{}
+
+	if !f.isDeterministic() {
+		so.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&so); err != nil {
+			return err
+		}
+	}
+
+	rd, err := f.getDescriptor(WithID(id))
+	if err != nil {
+		return err
+	}
+
+	if err := fn(rd); err != nil {
+		return err
+	}
+
+	rd.ModifiedAt = so.t.Unix()
 	f.h.ModifiedAt = so.t.Unix()
 
-	if err := f.writeHeader(); err != nil {
+	return f.commit()
+}
+
+// SetName sets the name of the data object with id to name, according to opts.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptSetDeterministic or OptSetWithTime.
+func (f *FileImage) SetName(id uint32, name string, opts ...SetOpt) error {
+	err := f.setDescriptor(id, func(rd *rawDescriptor) error {
+		return rd.setName(name)
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// SetGroupID places the data object with id in the data object group with the specified groupID,
+// according to opts. To remove the data object from its group, use SetNoGroup.
+//
+// Changing the group of a data object affects the relative IDs of objects in the affected groups,
+// and therefore invalidates any signatures that cover those groups.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptSetDeterministic or OptSetWithTime.
+func (f *FileImage) SetGroupID(id, groupID uint32, opts ...SetOpt) error {
+	if groupID == 0 {
+		return fmt.Errorf("%w", ErrInvalidGroupID)
+	}
+
+	err := f.setDescriptor(id, func(rd *rawDescriptor) error {
+		rd.GroupID = groupID | descrGroupMask
+		f.populateMinIDs()
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// SetNoGroup removes the data object with id from its data object group, according to opts.
+//
+// Changing the group of a data object affects the relative IDs of objects in the affected group,
+// and therefore invalidates any signatures that cover the group.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptSetDeterministic or OptSetWithTime.
+func (f *FileImage) SetNoGroup(id uint32, opts ...SetOpt) error {
+	err := f.setDescriptor(id, func(rd *rawDescriptor) error {
+		rd.GroupID = descrGroupMask
+		f.populateMinIDs()
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// SetLinkedID links the data object with id to the data object with the specified linkedID,
+// according to opts. If no data object with linkedID exists, an error wrapping ErrObjectNotFound
+// is returned. To remove the link, use SetNoLink.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptSetDeterministic or OptSetWithTime.
+func (f *FileImage) SetLinkedID(id, linkedID uint32, opts ...SetOpt) error {
+	if linkedID == 0 {
+		return fmt.Errorf("%w", ErrInvalidObjectID)
+	}
+
+	err := f.setDescriptor(id, func(rd *rawDescriptor) error {
+		if _, err := f.getDescriptor(WithID(linkedID)); err != nil {
+			return err
+		}
+
+		rd.LinkedID = linkedID
+		return nil
+	}, opts...)
+	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
+	return nil
+}
+
+// SetLinkedGroupID links the data object with id to the data object group with the specified
+// groupID, according to opts. If the group contains no data objects, an error wrapping
+// ErrObjectNotFound is returned. To remove the link, use SetNoLink.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptSetDeterministic or OptSetWithTime.
+func (f *FileImage) SetLinkedGroupID(id, groupID uint32, opts ...SetOpt) error {
+	if groupID == 0 {
+		return fmt.Errorf("%w", ErrInvalidGroupID)
+	}
+
+	err := f.setDescriptor(id, func(rd *rawDescriptor) error {
+		if ds, err := f.getDescriptors(WithGroupID(groupID)); err != nil {
+			return err
+		} else if len(ds) == 0 {
+			return fmt.Errorf("group %v: %w", groupID, ErrObjectNotFound)
+		}
 
+		rd.LinkedID = groupID | descrGroupMask
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// SetNoLink removes the link from the data object with id, according to opts.
+//
+// By default, the image/object modification times are set to the current time for
+// non-deterministic images, and unset otherwise. To override this, consider using
+// OptSetDeterministic or OptSetWithTime.
+func (f *FileImage) SetNoLink(id uint32, opts ...SetOpt) error {
+	err := f.setDescriptor(id, func(rd *rawDescriptor) error {
+		rd.LinkedID = 0
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// setHeader calls fn with the global header of f, and writes the updated header to backing
+// storage, according to opts.
+func (f *FileImage) setHeader(fn func(*header) error, opts ...SetOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWritable(); err != nil {
+		return err
+	}
+
+	so := setOpts{}
+
+	if !f.isDeterministic() {
+		so.t = time.Now()
+	}
+
+	for _, opt := range opts {
+		if err := opt(&so); err != nil {
+			return err
+		}
+	}
+
+	h := f.h
+
+	if err := fn(&h); err != nil {
+		return err
+	}
+
+	h.ModifiedAt = so.t.Unix()
+
+	f.h = h
+
+	return f.commit()
+}
+
+// SetLaunchScript sets the launch script of the image to s, according to opts.
+//
+// The launch script is covered by the header digest of signatures, so changing it invalidates
+// any signatures in the image.
+//
+// By default, the image modification time is set to the current time for non-deterministic
+// images, and unset otherwise. To override this, consider using OptSetDeterministic or
+// OptSetWithTime.
+func (f *FileImage) SetLaunchScript(s string, opts ...SetOpt) error {
+	err := f.setHeader(func(h *header) error {
+		b := []byte(s)
+
+		if len(b) >= len(h.LaunchScript) {
+			return errLaunchScriptLen
+		}
+
+		clear(h.LaunchScript[:])
+		copy(h.LaunchScript[:], b)
+
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// SetID sets the unique ID of the image to id, according to opts.
+//
+// The image ID is covered by the header digest of signatures, so changing it invalidates any
+// signatures in the image.
+//
+// By default, the image modification time is set to the current time for non-deterministic
+// images, and unset otherwise. To override this, consider using OptSetDeterministic or
+// OptSetWithTime.
+func (f *FileImage) SetID(id string, opts ...SetOpt) error {
+	err := f.setHeader(func(h *header) error {
+		u, err := uuid.Parse(id)
+		if err != nil {
+			return err
+		}
+
+		h.ID = u
+
+		return nil
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
 	return nil
 }
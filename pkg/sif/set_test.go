@@ -11,6 +11,8 @@ package sif
 
 import (
 	"errors"
+	"maps"
+	"slices"
 	"testing"
 	"time"
 
@@ -25,6 +27,7 @@ func TestSetPrimPart(t *testing.T) {
 		id         uint32
 		opts       []SetOpt
 		wantErr    error
+		wantArchs  []string
 	}{
 		{
 			name: "ErrObjectNotFound",
@@ -49,6 +52,7 @@ func TestSetPrimPart(t *testing.T) {
 			opts: []SetOpt{
 				OptSetDeterministic(),
 			},
+			wantArchs: []string{"386"},
 		},
 		{
 			name: "WithTime",
@@ -59,7 +63,7 @@ func TestSetPrimPart(t *testing.T) {
 						OptPartitionMetadata(FsRaw, PartPrimSys, "386"),
 					),
 					getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
-						OptPartitionMetadata(FsRaw, PartSystem, "amd64"),
+						OptPartitionMetadata(FsRaw, PartSystem, "386"),
 					),
 				),
 			},
@@ -67,6 +71,7 @@ func TestSetPrimPart(t *testing.T) {
 			opts: []SetOpt{
 				OptSetWithTime(time.Unix(946702800, 0)),
 			},
+			wantArchs: []string{"386"},
 		},
 		{
 			name: "One",
@@ -78,10 +83,27 @@ func TestSetPrimPart(t *testing.T) {
 					),
 				),
 			},
-			id: 1,
+			id:        1,
+			wantArchs: []string{"386"},
 		},
 		{
 			name: "Two",
+			createOpts: []CreateOpt{
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce},
+						OptPartitionMetadata(FsRaw, PartPrimSys, "386"),
+					),
+					getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+						OptPartitionMetadata(FsRaw, PartSystem, "386"),
+					),
+				),
+			},
+			id:        2,
+			wantArchs: []string{"386"},
+		},
+		{
+			name: "TwoArchs",
 			createOpts: []CreateOpt{
 				OptCreateDeterministic(),
 				OptCreateWithDescriptors(
@@ -93,7 +115,8 @@ func TestSetPrimPart(t *testing.T) {
 					),
 				),
 			},
-			id: 2,
+			id:        2,
+			wantArchs: []string{"386", "amd64"},
 		},
 	}
 
@@ -110,6 +133,10 @@ func TestSetPrimPart(t *testing.T) {
 				t.Errorf("got error %v, want %v", got, want)
 			}
 
+			if got, want := f.PrimaryArchs(), tt.wantArchs; !slices.Equal(got, want) {
+				t.Errorf("got primary architectures %v, want %v", got, want)
+			}
+
 			if err := f.UnloadContainer(); err != nil {
 				t.Error(err)
 			}
@@ -315,3 +342,426 @@ func TestFileImage_SetOCIBlobDigest(t *testing.T) {
 		})
 	}
 }
+
+func TestFileImage_SetName(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      uint32
+		objName string
+		opts    []SetOpt
+		wantErr error
+	}{
+		{
+			name:    "ErrObjectNotFound",
+			id:      2,
+			objName: "name",
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name:    "ErrNameTooLarge",
+			id:      1,
+			objName: string(make([]byte, descrNameLen+1)),
+			wantErr: errNameTooLarge,
+		},
+		{
+			name:    "Deterministic",
+			id:      1,
+			objName: "name",
+			opts: []SetOpt{
+				OptSetDeterministic(),
+			},
+		},
+		{
+			name:    "WithTime",
+			id:      1,
+			objName: "name",
+			opts: []SetOpt{
+				OptSetWithTime(time.Unix(946702800, 0)),
+			},
+		},
+		{
+			name:    "Empty",
+			id:      1,
+			objName: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}, OptObjectName("old")),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := f.SetName(tt.id, tt.objName, tt.opts...), tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_SetGroupID(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         uint32
+		groupID    uint32
+		noGroup    bool
+		opts       []SetOpt
+		wantErr    error
+		wantMinIDs map[uint32]uint32
+	}{
+		{
+			name:    "ErrObjectNotFound",
+			id:      3,
+			groupID: 2,
+			wantErr: ErrObjectNotFound,
+			wantMinIDs: map[uint32]uint32{
+				1 | descrGroupMask: 1,
+			},
+		},
+		{
+			name:    "ErrInvalidGroupID",
+			id:      1,
+			groupID: 0,
+			wantErr: ErrInvalidGroupID,
+			wantMinIDs: map[uint32]uint32{
+				1 | descrGroupMask: 1,
+			},
+		},
+		{
+			name:    "Deterministic",
+			id:      1,
+			groupID: 2,
+			opts: []SetOpt{
+				OptSetDeterministic(),
+			},
+			wantMinIDs: map[uint32]uint32{
+				1 | descrGroupMask: 2,
+				2 | descrGroupMask: 1,
+			},
+		},
+		{
+			name:    "WithTime",
+			id:      2,
+			groupID: 2,
+			opts: []SetOpt{
+				OptSetWithTime(time.Unix(946702800, 0)),
+			},
+			wantMinIDs: map[uint32]uint32{
+				1 | descrGroupMask: 1,
+				2 | descrGroupMask: 2,
+			},
+		},
+		{
+			name:    "NoGroup",
+			id:      1,
+			noGroup: true,
+			wantMinIDs: map[uint32]uint32{
+				descrGroupMask:     1,
+				1 | descrGroupMask: 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.noGroup {
+				err = f.SetNoGroup(tt.id, tt.opts...)
+			} else {
+				err = f.SetGroupID(tt.id, tt.groupID, tt.opts...)
+			}
+
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+
+			if got, want := f.minIDs, tt.wantMinIDs; !maps.Equal(got, want) {
+				t.Errorf("got min IDs %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_SetLinkedID(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          uint32
+		fn          func(*FileImage, uint32, ...SetOpt) error
+		opts        []SetOpt
+		wantErr     error
+		wantLink    uint32
+		wantIsGroup bool
+	}{
+		{
+			name: "ErrObjectNotFound",
+			id:   3,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedID(id, 1, opts...)
+			},
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name: "ErrInvalidObjectID",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedID(id, 0, opts...)
+			},
+			wantErr: ErrInvalidObjectID,
+		},
+		{
+			name: "LinkedObjectNotFound",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedID(id, 3, opts...)
+			},
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name: "ErrInvalidGroupID",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedGroupID(id, 0, opts...)
+			},
+			wantErr: ErrInvalidGroupID,
+		},
+		{
+			name: "LinkedGroupNotFound",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedGroupID(id, 2, opts...)
+			},
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name: "Deterministic",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedID(id, 1, opts...)
+			},
+			opts: []SetOpt{
+				OptSetDeterministic(),
+			},
+			wantLink: 1,
+		},
+		{
+			name: "WithTime",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedID(id, 1, opts...)
+			},
+			opts: []SetOpt{
+				OptSetWithTime(time.Unix(946702800, 0)),
+			},
+			wantLink: 1,
+		},
+		{
+			name: "LinkedGroupID",
+			id:   2,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetLinkedGroupID(id, 1, opts...)
+			},
+			wantLink:    1,
+			wantIsGroup: true,
+		},
+		{
+			name: "NoLink",
+			id:   1,
+			fn: func(f *FileImage, id uint32, opts ...SetOpt) error {
+				return f.SetNoLink(id, opts...)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithDescriptors(
+					getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}, OptLinkedID(2)),
+					getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed}, OptNoGroup()),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := tt.fn(f, tt.id, tt.opts...), tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if tt.wantErr == nil {
+				d, err := f.GetDescriptor(WithID(tt.id))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				id, isGroup := d.LinkedID()
+				if got, want := id, tt.wantLink; got != want {
+					t.Errorf("got linked ID %v, want %v", got, want)
+				}
+				if got, want := isGroup, tt.wantIsGroup; got != want {
+					t.Errorf("got linked group %v, want %v", got, want)
+				}
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_SetLaunchScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		opts    []SetOpt
+		wantErr error
+	}{
+		{
+			name:    "ErrLaunchScriptLen",
+			script:  string(make([]byte, hdrLaunchLen)),
+			wantErr: errLaunchScriptLen,
+		},
+		{
+			name:   "Deterministic",
+			script: "#!/usr/bin/env run-singularity\n",
+			opts: []SetOpt{
+				OptSetDeterministic(),
+			},
+		},
+		{
+			name:   "WithTime",
+			script: "#!/usr/bin/env run-singularity\n",
+			opts: []SetOpt{
+				OptSetWithTime(time.Unix(946702800, 0)),
+			},
+		},
+		{
+			name:   "Shorter",
+			script: "#!/bin/sh\n",
+		},
+		{
+			name:   "Empty",
+			script: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateDeterministic(),
+				OptCreateWithLaunchScript("#!/usr/bin/env other-runtime\n"),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := f.SetLaunchScript(tt.script, tt.opts...), tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFileImage_SetID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		opts    []SetOpt
+		wantErr bool
+	}{
+		{
+			name:    "InvalidID",
+			id:      "not-a-uuid",
+			wantErr: true,
+		},
+		{
+			name: "Deterministic",
+			id:   "de170c43-36ab-44a8-bca9-1ea1a070a274",
+			opts: []SetOpt{
+				OptSetDeterministic(),
+			},
+		},
+		{
+			name: "WithTime",
+			id:   "de170c43-36ab-44a8-bca9-1ea1a070a274",
+			opts: []SetOpt{
+				OptSetWithTime(time.Unix(946702800, 0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Buffer
+
+			f, err := CreateContainer(&b,
+				OptCreateWithID("1fe5be3d-4b8a-4d0c-8c0b-0b8b7f5e3c2a"),
+				OptCreateWithTime(time.Unix(946702800, 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.SetID(tt.id, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			g := goldie.New(t, goldie.WithTestNameForDir(true))
+			g.Assert(t, tt.name, b.Bytes())
+		})
+	}
+}
@@ -82,6 +82,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -359,65 +360,145 @@ type ReadWriter interface {
 }
 
 // FileImage describes the representation of a SIF file in memory.
+//
+// The methods of FileImage are safe for concurrent use by multiple goroutines, provided the
+// backing storage supports concurrent use of ReadAt alongside other methods, as *os.File and
+// *Buffer do. Methods that modify the image are serialized, and exclude concurrent calls to
+// methods that read the image. Selector funcs and other callbacks are called with the image
+// locked, and must not call methods of the FileImage.
+//
+// A Descriptor is a snapshot of a data object. If the data object is replaced, deleted or
+// relocated by a concurrent modification, reading its data may return unexpected results.
 type FileImage struct {
 	rw ReadWriter // Backing storage for image.
 
+	mu  sync.RWMutex    // Guards the fields below.
 	h   header          // Raw global header from image.
 	rds []rawDescriptor // Raw descriptors from image.
 
 	closeOnUnload bool              // Close rw on Unload.
+	readOnly      bool              // Image loaded read-only.
+	journaled     bool              // Modifications are journaled.
+	inBatch       bool              // Modifications are being staged by Batch.
+	batchSize     int64             // Size of image when Batch was called.
 	minIDs        map[uint32]uint32 // Minimum object IDs for each group ID.
+	maxObjectSize int64             // Maximum size of data object read into memory, or zero.
+}
+
+// getHeader returns a copy of the global header of f.
+func (f *FileImage) getHeader() header {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.h
 }
 
 // LaunchScript returns the image launch script.
 func (f *FileImage) LaunchScript() string {
-	return string(bytes.TrimRight(f.h.LaunchScript[:], "\x00"))
+	h := f.getHeader()
+	return string(bytes.TrimRight(h.LaunchScript[:], "\x00"))
 }
 
 // Version returns the SIF specification version of the image.
 func (f *FileImage) Version() string {
-	return string(bytes.TrimRight(f.h.Version[:], "\x00"))
+	h := f.getHeader()
+	return string(bytes.TrimRight(h.Version[:], "\x00"))
 }
 
 // PrimaryArch returns the primary CPU architecture of the image, or "unknown" if the primary CPU
-// architecture cannot be determined.
-func (f *FileImage) PrimaryArch() string { return f.h.Arch.GoArch() }
+// architecture cannot be determined. If the image contains primary system partitions for more
+// than one CPU architecture, the architecture recorded in the global header is returned. To
+// obtain all architectures, use PrimaryArchs.
+func (f *FileImage) PrimaryArch() string { return f.getHeader().Arch.GoArch() }
+
+// PrimaryArchs returns the CPU architectures of the primary system partitions in the image, in
+// descriptor order.
+func (f *FileImage) PrimaryArchs() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var archs []string
+	for _, rd := range f.rds {
+		if !rd.Used {
+			continue
+		}
+
+		if _, pt, arch, err := rd.getPartitionMetadata(); err == nil && pt == PartPrimSys {
+			archs = append(archs, arch)
+		}
+	}
+	return archs
+}
+
+// GetPrimaryPartition returns the descriptor of the primary system partition for CPU architecture
+// arch. The value of arch should be the architecture as represented by the Go runtime, such as
+// runtime.GOARCH. If the image does not contain a primary system partition for arch, an error
+// wrapping ErrObjectNotFound is returned.
+func (f *FileImage) GetPrimaryPartition(arch string) (Descriptor, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	d, err := f.descriptor(WithPartitionType(PartPrimSys), WithPartitionArch(arch))
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("%w", err)
+	}
+	return d, nil
+}
+
+// primaryArch returns the CPU architecture of the first primary system partition in f, or
+// hdrArchUnknown if f does not contain a primary system partition.
+func (f *FileImage) primaryArch() archType {
+	for _, rd := range f.rds {
+		if !rd.Used || !rd.isPartitionOfType(PartPrimSys) {
+			continue
+		}
+
+		var p partition
+		if err := rd.getExtra(binaryUnmarshaler{&p}); err == nil {
+			return p.Arch
+		}
+	}
+	return hdrArchUnknown
+}
 
 // ID returns the ID of the image.
-func (f *FileImage) ID() string { return f.h.ID.String() }
+func (f *FileImage) ID() string { return f.getHeader().ID.String() }
 
 // CreatedAt returns the creation time of the image.
-func (f *FileImage) CreatedAt() time.Time { return time.Unix(f.h.CreatedAt, 0) }
+func (f *FileImage) CreatedAt() time.Time { return time.Unix(f.getHeader().CreatedAt, 0) }
 
 // ModifiedAt returns the last modification time of the image.
-func (f *FileImage) ModifiedAt() time.Time { return time.Unix(f.h.ModifiedAt, 0) }
+func (f *FileImage) ModifiedAt() time.Time { return time.Unix(f.getHeader().ModifiedAt, 0) }
 
 // DescriptorsFree returns the number of free descriptors in the image.
-func (f *FileImage) DescriptorsFree() int64 { return f.h.DescriptorsFree }
+func (f *FileImage) DescriptorsFree() int64 { return f.getHeader().DescriptorsFree }
 
 // DescriptorsTotal returns the total number of descriptors in the image.
-func (f *FileImage) DescriptorsTotal() int64 { return f.h.DescriptorsTotal }
+func (f *FileImage) DescriptorsTotal() int64 { return f.getHeader().DescriptorsTotal }
 
 // DescriptorsOffset returns the offset (in bytes) of the descriptors section in the image.
-func (f *FileImage) DescriptorsOffset() int64 { return f.h.DescriptorsOffset }
+func (f *FileImage) DescriptorsOffset() int64 { return f.getHeader().DescriptorsOffset }
 
 // DescriptorsSize returns the size (in bytes) of the descriptors section in the image.
-func (f *FileImage) DescriptorsSize() int64 { return f.h.DescriptorsSize }
+func (f *FileImage) DescriptorsSize() int64 { return f.getHeader().DescriptorsSize }
 
 // DataOffset returns the offset (in bytes) of the data section in the image.
-func (f *FileImage) DataOffset() int64 { return f.h.DataOffset }
+func (f *FileImage) DataOffset() int64 { return f.getHeader().DataOffset }
 
 // DataSize returns the size (in bytes) of the data section in the image.
-func (f *FileImage) DataSize() int64 { return f.h.DataSize }
+func (f *FileImage) DataSize() int64 { return f.getHeader().DataSize }
 
 // GetHeaderIntegrityReader returns an io.Reader that reads the integrity-protected fields from the
 // header of the image.
 func (f *FileImage) GetHeaderIntegrityReader() io.Reader {
-	return f.h.GetIntegrityReader()
+	h := f.getHeader()
+	return h.GetIntegrityReader()
 }
 
 // isDeterministic returns true if the UUID and timestamps in the header of f are set to
 // deterministic values.
 func (f *FileImage) isDeterministic() bool {
-	return f.h.ID == uuid.Nil && f.CreatedAt().IsZero() && f.ModifiedAt().IsZero()
+	return f.h.ID == uuid.Nil &&
+		time.Unix(f.h.CreatedAt, 0).IsZero() &&
+		time.Unix(f.h.ModifiedAt, 0).IsZero()
 }
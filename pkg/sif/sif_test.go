@@ -12,8 +12,11 @@ package sif
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/google/uuid"
@@ -106,3 +109,228 @@ func TestHeader_GetIntegrityReader(t *testing.T) {
 		})
 	}
 }
+
+func TestFileImage_GetPrimaryPartition(t *testing.T) {
+	var b Buffer
+
+	f, err := CreateContainer(&b,
+		OptCreateDeterministic(),
+		OptCreateWithDescriptors(
+			getDescriptorInput(t, DataPartition, []byte{0xfa, 0xce},
+				OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+			),
+			getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+				OptPartitionMetadata(FsSquash, PartPrimSys, "amd64"),
+			),
+			getDescriptorInput(t, DataPartition, []byte{0xde, 0xad},
+				OptPartitionMetadata(FsSquash, PartSystem, "arm64"),
+			),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := f.PrimaryArch(), "386"; got != want {
+		t.Errorf("got primary architecture %v, want %v", got, want)
+	}
+
+	if got, want := f.PrimaryArchs(), []string{"386", "amd64"}; !slices.Equal(got, want) {
+		t.Errorf("got primary architectures %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		arch    string
+		wantID  uint32
+		wantErr error
+	}{
+		{
+			name:    "UnknownArchitecture",
+			arch:    "cray",
+			wantErr: errUnknownArchitcture,
+		},
+		{
+			name:    "NotPrimary",
+			arch:    "arm64",
+			wantErr: ErrObjectNotFound,
+		},
+		{
+			name:   "386",
+			arch:   "386",
+			wantID: 1,
+		},
+		{
+			name:   "AMD64",
+			arch:   "amd64",
+			wantID: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := f.GetPrimaryPartition(tt.arch)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if got, want := d.ID(), tt.wantID; got != want {
+				t.Errorf("got ID %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Deleting the first primary partition updates the architecture in the global header.
+	if err := f.DeleteObject(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := f.PrimaryArch(), "amd64"; got != want {
+		t.Errorf("got primary architecture %v, want %v", got, want)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileImage_Concurrent(t *testing.T) {
+	const n = 8
+
+	newImage := func() *FileImage {
+		var b Buffer
+
+		f, err := CreateContainer(&b,
+			OptCreateDeterministic(),
+			OptCreateWithDescriptorCapacity(1),
+			OptCreateWithDescriptors(
+				getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+			),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	f := newImage()
+	g := newImage()
+
+	var wg sync.WaitGroup
+
+	for range n {
+		// Modify the image, growing the descriptor section as required.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			di := getDescriptorInput(t, DataGeneric, []byte{0xfe, 0xed})
+			if err := f.AddObject(di, OptAddWithDescriptorGrowth(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := f.Batch(func(tx *Tx) error {
+				return tx.SetLaunchScript("#!/bin/sh\n")
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+
+		// Update descriptors, including their groups, which affects object selection.
+		for _, set := range []func() error{
+			func() error { return f.SetGroupID(1, 2) },
+			func() error { return f.SetNoGroup(1) },
+			func() error { return f.SetLinkedID(1, 1) },
+			func() error { return f.SetNoLink(1) },
+			func() error { return f.SetName(1, "name") },
+		} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if err := set(); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if _, err := f.GetDescriptors(); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		// Copy between images in both directions, which must not deadlock.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := f.CopyObjects(g, WithID(1), OptCopyWithDescriptorGrowth(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := g.CopyObjects(f, WithID(1), OptCopyWithDescriptorGrowth(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		// Read the image, which must be consistent.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if fs, err := f.Check(); err != nil {
+				t.Error(err)
+			} else if len(fs) > 0 {
+				t.Errorf("got findings %v", fs)
+			}
+
+			ds, err := f.GetDescriptors()
+			if err != nil {
+				t.Error(err)
+			}
+
+			for _, d := range ds {
+				if _, err := io.Copy(io.Discard, d.GetReader()); err != nil {
+					t.Error(err)
+				}
+			}
+
+			if got := f.DescriptorsTotal() - f.DescriptorsFree(); got < 1 {
+				t.Errorf("got %v descriptors in use, want at least 1", got)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got, want := f.DescriptorsTotal()-f.DescriptorsFree(), int64(1+2*n); got != want {
+		t.Errorf("got %v descriptors in use, want %v", got, want)
+	}
+
+	if got, want := g.DescriptorsTotal()-g.DescriptorsFree(), int64(1+n); got != want {
+		t.Errorf("got %v descriptors in use, want %v", got, want)
+	}
+
+	for _, f := range []*FileImage{f, g} {
+		if fs, err := f.Check(); err != nil {
+			t.Error(err)
+		} else if len(fs) > 0 {
+			t.Errorf("got findings %v", fs)
+		}
+	}
+}
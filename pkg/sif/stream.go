@@ -0,0 +1,179 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	errUnknownObjectSize     = errors.New("data object size unknown")
+	errUnknownOCIBlobDigest  = errors.New("OCI blob digest unknown")
+	errObjectSizeMismatch    = errors.New("data object size does not match data read")
+	errDescriptorsOffsetSize = errors.New("descriptors offset too small for global header")
+)
+
+// streamWriter is an io.Writer that tracks the number of bytes written to the underlying writer.
+type streamWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	n, err := sw.w.Write(b)
+	sw.n += int64(n)
+	return n, err
+}
+
+// padTo writes zero bytes to sw until offset is reached.
+func (sw *streamWriter) padTo(offset int64) error {
+	_, err := io.CopyN(sw, zeroReader{}, offset-sw.n)
+	return err
+}
+
+// layoutDataObjects populates the descriptors in f with the details of the data objects specified
+// in co, without writing any data.
+func (f *FileImage) layoutDataObjects(co createOpts) error {
+	for i, di := range co.dis {
+		if di.opts.size < 0 {
+			return fmt.Errorf("data object %v: %w", i+1, errUnknownObjectSize)
+		}
+
+		// The digest of an OCI blob must be known before the descriptor is written.
+		if ob, ok := di.opts.md.(*ociBlob); ok && ob.digest.Hex == "" {
+			return fmt.Errorf("data object %v: %w", i+1, errUnknownOCIBlobDigest)
+		}
+
+		d, err := f.allocateDescriptor(i, di)
+		if err != nil {
+			return err
+		}
+
+		offsetUnaligned := f.h.DataOffset + f.h.DataSize
+
+		offset, err := nextAligned(offsetUnaligned, di.opts.alignment)
+		if err != nil {
+			return err
+		}
+
+		if err := di.fillDescriptor(co.t, d); err != nil {
+			return err
+		}
+		d.Used = true
+		d.Offset = offset
+		d.Size = di.opts.size
+		d.SizeWithPadding = offset - offsetUnaligned + d.Size
+
+		f.commitDescriptor(d)
+	}
+
+	return nil
+}
+
+// writeStream writes the global header, descriptors and data objects described by f and co to w
+// sequentially.
+func (f *FileImage) writeStream(w io.Writer, co createOpts) error {
+	sw := &streamWriter{w: w}
+
+	if err := binary.Write(sw, binary.LittleEndian, f.h); err != nil {
+		return err
+	}
+
+	if sw.n > f.h.DescriptorsOffset {
+		return errDescriptorsOffsetSize
+	}
+
+	if err := sw.padTo(f.h.DescriptorsOffset); err != nil {
+		return err
+	}
+
+	if err := binary.Write(sw, binary.LittleEndian, f.rds); err != nil {
+		return err
+	}
+
+	p := newProgress(co.ctx, co.progress, inputSize(co.dis))
+
+	for i, di := range co.dis {
+		d := &f.rds[i]
+		r := p.reader(di.r)
+
+		// Padding is written only when followed by data, to match the output of CreateContainer.
+		if d.Size > 0 {
+			if err := sw.padTo(d.Offset); err != nil {
+				return err
+			}
+		}
+
+		if _, err := io.CopyN(sw, r, d.Size); errors.Is(err, io.EOF) {
+			return fmt.Errorf("data object %v: %w", d.ID, errObjectSizeMismatch)
+		} else if err != nil {
+			return err
+		}
+
+		// Ensure the reader does not contain more data than expected.
+		if n, err := di.r.Read(make([]byte, 1)); n > 0 {
+			return fmt.Errorf("data object %v: %w", d.ID, errObjectSizeMismatch)
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteContainer writes a new SIF image to w, according to opts. One or more data objects can
+// optionally be specified using OptCreateWithDescriptors.
+//
+// Unlike CreateContainer, the image is written sequentially, so w need not support seeking. The
+// layout of the image is calculated before any data is written, so the size of each data object
+// must be known in advance. The size is determined from the reader supplied to NewDescriptorInput
+// where possible. Otherwise, it must be specified using OptObjectSize. If the amount of data read
+// does not match the specified size, an error is returned. Similarly, the digest of data objects
+// of type DataOCIRootIndex or DataOCIBlob must be specified using OptOCIBlobDigest.
+//
+// For the same options and data, the image written is identical to that produced by
+// CreateContainer.
+//
+// By default, the image ID is set to a randomly generated value. To override this, consider using
+// OptCreateDeterministic or OptCreateWithID.
+//
+// By default, the image creation time is set to the current time. To override this, consider using
+// OptCreateDeterministic or OptCreateWithTime.
+//
+// By default, the image will support a maximum of 48 descriptors. To change this, consider using
+// OptCreateWithDescriptorCapacity.
+//
+// A launch script can optionally be set using OptCreateWithLaunchScript.
+//
+// To stop writing large data objects on request, consider using OptCreateWithContext. To report
+// progress as data objects are written, consider using OptCreateWithProgress.
+func WriteContainer(w io.Writer, opts ...CreateOpt) error {
+	co, err := getCreateOpts(opts...)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	f, err := newFileImage(nil, co)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := f.layoutDataObjects(co); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := f.writeStream(w, co); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestWriteContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    func(t *testing.T) []CreateOpt
+		wantErr error
+	}{
+		{
+			name: "Empty",
+			opts: func(*testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+				}
+			},
+		},
+		{
+			name: "EmptyLaunchScript",
+			opts: func(*testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithLaunchScript("#!/usr/bin/env launch-script\n"),
+				}
+			},
+		},
+		{
+			name: "WithTime",
+			opts: func(*testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateWithID("de170c43-36ab-44a8-bca9-1ea1a070a274"),
+					OptCreateWithTime(time.Unix(946702800, 0)),
+				}
+			},
+		},
+		{
+			name: "ErrInsufficientCapacity",
+			opts: func(t *testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithDescriptorCapacity(0),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					),
+				}
+			},
+			wantErr: errInsufficientCapacity,
+		},
+		{
+			name: "OneObject",
+			opts: func(t *testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+					),
+				}
+			},
+		},
+		{
+			name: "TwoObjects",
+			opts: func(t *testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+						getDescriptorInput(t, DataPartition, []byte{0xfe, 0xed},
+							OptPartitionMetadata(FsSquash, PartPrimSys, "386"),
+						),
+					),
+				}
+			},
+		},
+		{
+			name: "ZeroSizeObject",
+			opts: func(t *testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataGeneric, []byte{0xfa, 0xce}),
+						getDescriptorInput(t, DataGeneric, []byte{}, OptObjectAlignment(4096)),
+					),
+				}
+			},
+		},
+		{
+			name: "OptObjectSize",
+			opts: func(t *testing.T) []CreateOpt {
+				di, err := NewDescriptorInput(DataGeneric,
+					io.MultiReader(bytes.NewReader([]byte{0xfa, 0xce})),
+					OptObjectSize(2),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(di),
+				}
+			},
+		},
+		{
+			name: "OptOCIBlobDigest",
+			opts: func(t *testing.T) []CreateOpt {
+				return []CreateOpt{
+					OptCreateDeterministic(),
+					OptCreateWithDescriptors(
+						getDescriptorInput(t, DataOCIBlob, []byte{0xfa, 0xce},
+							OptOCIBlobDigest(v1.Hash{
+								Algorithm: "sha256",
+								Hex:       "44d2a93d04cbb1acf5406dcc6a81a439d2cf17a79f22a19d094dcb78dc852f80",
+							}),
+						),
+					),
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			if got, want := WriteContainer(&b, tt.opts(t)...), tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			var want Buffer
+
+			f, err := CreateContainer(&want, tt.opts(t)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.UnloadContainer(); err != nil {
+				t.Error(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), want.Bytes()) {
+				t.Errorf("image does not match image created with CreateContainer")
+			}
+		})
+	}
+}
+
+func TestWriteContainerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       DataType
+		r       io.Reader
+		opts    []DescriptorInputOpt
+		wantErr error
+	}{
+		{
+			name:    "UnknownObjectSize",
+			t:       DataGeneric,
+			r:       io.MultiReader(bytes.NewReader([]byte{0xfa, 0xce})),
+			wantErr: errUnknownObjectSize,
+		},
+		{
+			name:    "UnknownOCIBlobDigest",
+			t:       DataOCIBlob,
+			r:       bytes.NewReader([]byte{0xfa, 0xce}),
+			wantErr: errUnknownOCIBlobDigest,
+		},
+		{
+			name:    "ObjectTooSmall",
+			t:       DataGeneric,
+			r:       io.MultiReader(bytes.NewReader([]byte{0xfa, 0xce})),
+			opts:    []DescriptorInputOpt{OptObjectSize(3)},
+			wantErr: errObjectSizeMismatch,
+		},
+		{
+			name:    "ObjectTooLarge",
+			t:       DataGeneric,
+			r:       io.MultiReader(bytes.NewReader([]byte{0xfa, 0xce})),
+			opts:    []DescriptorInputOpt{OptObjectSize(1)},
+			wantErr: errObjectSizeMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			di, err := NewDescriptorInput(tt.t, tt.r, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = WriteContainer(io.Discard, OptCreateDeterministic(), OptCreateWithDescriptors(di))
+
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// getCheck returns a command that checks the structural consistency of an image.
+func (c *command) getCheck() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check <sif_path>",
+		Short: "Check image consistency",
+		Long: `Check the structural consistency of a SIF image, and display any inconsistencies
+found. If an inconsistency of severity "error" is found, the command exits with a
+non-zero status.
+
+The image may be specified as a path, or as an HTTP(S) URL if the server supports
+range requests.`,
+		Example: c.opts.rootPath + " check image.sif",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: c.initApp,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return c.app.Check(args[0])
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// getCopy returns a command that copies data objects between SIF images.
+func (c *command) getCopy() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "copy [flags] <src_path> <dst_path>",
+		Short: "Copy data objects between images",
+		Long: `Copy data objects from one SIF image to another. Data objects with the IDs
+specified by --id, and data objects in the groups specified by --group, are
+copied. If neither flag is specified, all data objects are copied.
+
+Copied data objects are assigned new IDs in the destination image, and each group
+containing a copied data object is assigned a new group ID. Links between copied
+data objects are updated accordingly.
+
+The source image may be specified as a path, or as an HTTP(S) URL if the server
+supports range requests.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " copy src.sif dst.sif",
+			c.opts.rootPath + " copy --id 3 --id 4 src.sif dst.sif",
+			c.opts.rootPath + " copy --group 1 src.sif dst.sif",
+		}, "\n"),
+		Args: cobra.ExactArgs(2),
+	}
+
+	ids := cmd.Flags().UintSlice("id", nil, "copy data object with ID")
+	groupIDs := cmd.Flags().UintSlice("group", nil, "copy data objects in group with ID")
+
+	cmd.PreRunE = c.initApp
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		ids, err := toUint32s(*ids)
+		if err != nil {
+			return fmt.Errorf("while converting id: %w", err)
+		}
+
+		groupIDs, err := toUint32s(*groupIDs)
+		if err != nil {
+			return fmt.Errorf("while converting group id: %w", err)
+		}
+
+		return c.app.Copy(args[0], args[1], ids, groupIDs)
+	}
+
+	return cmd
+}
+
+var errValueOutOfRange = errors.New("value out of range")
+
+// toUint32s converts vs to a slice of uint32 values.
+func toUint32s(vs []uint) ([]uint32, error) {
+	us := make([]uint32, 0, len(vs))
+	for _, v := range vs {
+		if v > math.MaxUint32 {
+			return nil, fmt.Errorf("%w: %v", errValueOutOfRange, v)
+		}
+		us = append(us, uint32(v))
+	}
+	return us, nil
+}
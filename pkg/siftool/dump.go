@@ -22,9 +22,12 @@ import (
 // getDump returns a command that dumps a data object from a SIF file.
 func (c *command) getDump() *cobra.Command {
 	return &cobra.Command{
-		Use:     "dump <id> <sif_path>",
-		Short:   "Dump data object",
-		Long:    "Dump a data object from a SIF image.",
+		Use:   "dump <id> <sif_path>",
+		Short: "Dump data object",
+		Long: `Dump a data object from a SIF image.
+
+The image may be specified as a path, or as an HTTP(S) URL if the server supports
+range requests.`,
 		Example: c.opts.rootPath + " dump 1 image.sif",
 		Args:    cobra.ExactArgs(2),
 		PreRunE: c.initApp,
@@ -0,0 +1,67 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// getExtract returns a command that extracts a subset of a SIF image into a new image.
+func (c *command) getExtract() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "extract [flags] <src_path> <dst_path>",
+		Short: "Extract data objects into a new image",
+		Long: `Extract a subset of the data objects in a SIF image into a new image. Data objects
+with the IDs specified by --id, and data objects in the groups specified by
+--group, are included. If neither flag is specified, all data objects are
+included. Data objects with the IDs specified by --exclude are not included,
+unless an included data object is linked to them.
+
+The header and data object descriptors of the source image are preserved, so
+signatures remain valid for data object groups that are included in their
+entirety.
+
+The source image may be specified as a path, or as an HTTP(S) URL if the server
+supports range requests.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " extract --group 1 src.sif dst.sif",
+			c.opts.rootPath + " extract --exclude 1 --exclude 5 src.sif dst.sif",
+		}, "\n"),
+		Args: cobra.ExactArgs(2),
+	}
+
+	ids := cmd.Flags().UintSlice("id", nil, "include data object with ID")
+	groupIDs := cmd.Flags().UintSlice("group", nil, "include data objects in group with ID")
+	excludeIDs := cmd.Flags().UintSlice("exclude", nil, "exclude data object with ID")
+
+	cmd.PreRunE = c.initApp
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		ids, err := toUint32s(*ids)
+		if err != nil {
+			return fmt.Errorf("while converting id: %w", err)
+		}
+
+		groupIDs, err := toUint32s(*groupIDs)
+		if err != nil {
+			return fmt.Errorf("while converting group id: %w", err)
+		}
+
+		excludeIDs, err := toUint32s(*excludeIDs)
+		if err != nil {
+			return fmt.Errorf("while converting excluded id: %w", err)
+		}
+
+		return c.app.Extract(args[0], args[1], ids, groupIDs, excludeIDs)
+	}
+
+	return cmd
+}
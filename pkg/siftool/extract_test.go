@@ -0,0 +1,52 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_command_getExtract(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  commandOpts
+		flags []string
+	}{
+		{
+			name: "All",
+		},
+		{
+			name:  "ID",
+			flags: []string{"--id", "3"},
+		},
+		{
+			name:  "Group",
+			flags: []string{"--group", "2"},
+		},
+		{
+			name:  "Exclude",
+			flags: []string{"--exclude", "1", "--exclude", "4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getExtract()
+
+			args := append(tt.flags,
+				filepath.Join(corpus, "two-groups-signed-dsse.sif"),
+				filepath.Join(t.TempDir(), "sif"),
+			)
+
+			runCommand(t, cmd, args, nil)
+		})
+	}
+}
@@ -19,9 +19,12 @@ import (
 // getHeader returns a command that displays the global SIF header.
 func (c *command) getHeader() *cobra.Command {
 	return &cobra.Command{
-		Use:     "header <sif_path>",
-		Short:   "Display global header",
-		Long:    "Display global header from a SIF image.",
+		Use:   "header <sif_path>",
+		Short: "Display global header",
+		Long: `Display global header from a SIF image.
+
+The image may be specified as a path, or as an HTTP(S) URL if the server supports
+range requests.`,
 		Example: c.opts.rootPath + " header image.sif",
 		Args:    cobra.ExactArgs(1),
 		PreRunE: c.initApp,
@@ -65,6 +65,10 @@ func Test_command_getHeader(t *testing.T) {
 			name: "TwoGroupsSignedPGP",
 			path: filepath.Join(corpus, "two-groups-signed-pgp.sif"),
 		},
+		{
+			name: "TwoArchs",
+			path: filepath.Join(corpus, "two-archs.sif"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -23,9 +23,12 @@ import (
 // image.
 func (c *command) getInfo() *cobra.Command {
 	return &cobra.Command{
-		Use:     "info <id> <sif_path>",
-		Short:   "Display data object info",
-		Long:    "Display info about a data object from a SIF image.",
+		Use:   "info <id> <sif_path>",
+		Short: "Display data object info",
+		Long: `Display info about a data object from a SIF image.
+
+The image may be specified as a path, or as an HTTP(S) URL if the server supports
+range requests.`,
 		Example: c.opts.rootPath + " info 1 image.sif",
 		Args:    cobra.ExactArgs(2),
 		PreRunE: c.initApp,
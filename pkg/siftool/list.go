@@ -19,9 +19,12 @@ import (
 // getList returns a command that lists object descriptors from a SIF image.
 func (c *command) getList() *cobra.Command {
 	return &cobra.Command{
-		Use:     "list <sif_path>",
-		Short:   "List data objects",
-		Long:    "List data objects from a SIF image.",
+		Use:   "list <sif_path>",
+		Short: "List data objects",
+		Long: `List data objects from a SIF image.
+
+The image may be specified as a path, or as an HTTP(S) URL if the server supports
+range requests.`,
 		Example: c.opts.rootPath + " list image.sif",
 		Args:    cobra.ExactArgs(1),
 		PreRunE: c.initApp,
@@ -0,0 +1,88 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// getOCI returns a command that converts between OCI content and SIF images.
+func (c *command) getOCI() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "oci",
+		Short: "Convert between OCI images and SIF",
+		Long:  "Convert between OCI images and SIF images containing OCI content.",
+	}
+
+	cmd.AddCommand(
+		c.getOCIImport(),
+		c.getOCIExport(),
+	)
+
+	return cmd
+}
+
+// getOCIImport returns a command that imports OCI content into a new SIF image.
+func (c *command) getOCIImport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import [flags] <src_path> <sif_path>",
+		Short: "Import OCI image into a new SIF image",
+		Long: `Import an OCI image layout directory, or a tar archive containing an OCI image
+layout or an image written by 'docker save', into a new SIF image.
+
+The index of the OCI image layout is stored as the OCI root index of the SIF
+image, and each referenced manifest, config and layer is stored as an OCI blob.
+Blobs are deduplicated by digest. Images in a 'docker save' archive are stored in
+a new OCI index.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " oci import layout/ image.sif",
+			c.opts.rootPath + " oci import --deterministic image.tar image.sif",
+		}, "\n"),
+		Args: cobra.ExactArgs(2),
+	}
+
+	deterministic := cmd.Flags().Bool("deterministic", false, "create image deterministically")
+
+	cmd.PreRunE = c.initApp
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		return c.app.OCIImport(args[0], args[1], *deterministic)
+	}
+
+	return cmd
+}
+
+// getOCIExport returns a command that exports OCI content from a SIF image.
+func (c *command) getOCIExport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [flags] <sif_path> <dst_path>",
+		Short: "Export OCI image from a SIF image",
+		Long: `Export the OCI content of a SIF image to a new OCI image layout directory, or to a
+tar archive containing an OCI image layout.
+
+The OCI root index of the SIF image is written as the index of the OCI image
+layout, and each referenced manifest, config and layer is written as a blob. The
+content of each blob is verified as it is written.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " oci export image.sif layout/",
+			c.opts.rootPath + " oci export --archive image.sif image.tar",
+		}, "\n"),
+		Args: cobra.ExactArgs(2),
+	}
+
+	archive := cmd.Flags().Bool("archive", false, "write a tar archive instead of a directory")
+
+	cmd.PreRunE = c.initApp
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		return c.app.OCIExport(args[0], args[1], *archive)
+	}
+
+	return cmd
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/oci"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// makeTestLayout writes a random OCI image layout, and returns its path.
+func makeTestLayout(t *testing.T) string {
+	t.Helper()
+
+	ii, err := random.Index(64, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "layout")
+
+	if _, err := layout.Write(path, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_command_getOCIImport(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  commandOpts
+		flags []string
+	}{
+		{
+			name: "OK",
+		},
+		{
+			name:  "Deterministic",
+			flags: []string{"--deterministic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getOCIImport()
+
+			args := append(tt.flags,
+				makeTestLayout(t),
+				filepath.Join(t.TempDir(), "sif"),
+			)
+
+			runCommand(t, cmd, args, nil)
+		})
+	}
+}
+
+// makeTestOCIImage writes a SIF image containing a random OCI image, and returns its path.
+func makeTestOCIImage(t *testing.T) string {
+	t.Helper()
+
+	ii, err := oci.ImageIndexFromPath(makeTestLayout(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.sif")
+
+	f, err := sif.CreateContainerAtPath(path, sif.OptCreateDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := oci.AddImageIndex(f, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_command_getOCIExport(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  commandOpts
+		flags []string
+	}{
+		{
+			name: "Layout",
+		},
+		{
+			name:  "Archive",
+			flags: []string{"--archive"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getOCIExport()
+
+			args := append(tt.flags,
+				makeTestOCIImage(t),
+				filepath.Join(t.TempDir(), "dst"),
+			)
+
+			runCommand(t, cmd, args, nil)
+		})
+	}
+}
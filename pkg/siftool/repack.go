@@ -0,0 +1,30 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// getRepack returns a command that defragments the data section of a SIF image.
+func (c *command) getRepack() *cobra.Command {
+	return &cobra.Command{
+		Use:   "repack <sif_path>",
+		Short: "Repack SIF image",
+		Long: `Repack a SIF image, eliminating unused space between data objects and at the
+end of the image.`,
+		Example: c.opts.rootPath + " repack image.sif",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: c.initApp,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return c.app.Repack(args[0])
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// getRepair returns a command that repairs structural inconsistencies in an image.
+func (c *command) getRepair() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repair [flags] <src_path> [dst_path]",
+		Short: "Repair damaged image",
+		Long: `Repair structural inconsistencies in a SIF image, and display the modifications
+made. By default, the repaired image is written to dst_path, and the source image
+is not modified. If --in-place is specified, the source image is modified.
+
+Data object descriptors that refer to data beyond the end of the image are
+removed, duplicate primary system partitions are changed to system partitions,
+and the global header is recomputed. Modifications may invalidate existing
+signatures.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " repair src.sif dst.sif",
+			c.opts.rootPath + " repair --in-place image.sif",
+		}, "\n"),
+	}
+
+	inPlace := cmd.Flags().Bool("in-place", false, "modify source image in place")
+
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if *inPlace {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	}
+	cmd.PreRunE = c.initApp
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		if *inPlace {
+			return c.app.Repair(args[0], "")
+		}
+		return c.app.Repair(args[0], args[1])
+	}
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_command_getRepair(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  commandOpts
+		flags []string
+	}{
+		{
+			name: "Copy",
+		},
+		{
+			name:  "InPlace",
+			flags: []string{"--in-place"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := makeTestSIF(t, true)
+
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Truncate(path, fi.Size()-1); err != nil {
+				t.Fatal(err)
+			}
+
+			c := &command{opts: tt.opts}
+
+			cmd := c.getRepair()
+
+			args := append(tt.flags, path)
+			if len(tt.flags) == 0 {
+				args = append(args, filepath.Join(t.TempDir(), "sif"))
+			}
+
+			runCommand(t, cmd, args, nil)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// getSet returns a command that modifies the header or data object descriptors of an image.
+func (c *command) getSet() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Modify header or data object descriptor",
+		Long:  "Modify the global header or a data object descriptor in a SIF image.",
+	}
+
+	cmd.AddCommand(
+		c.getSetName(),
+		c.getSetGroup(),
+		c.getSetLink(),
+		c.getSetLaunchScript(),
+		c.getSetID(),
+	)
+
+	return cmd
+}
+
+// getSetName returns a command that sets the name of a data object.
+func (c *command) getSetName() *cobra.Command {
+	return &cobra.Command{
+		Use:     "name <id> <name> <sif_path>",
+		Short:   "Set data object name",
+		Long:    "Set the name of a data object in a SIF image.",
+		Example: c.opts.rootPath + " set name 1 rootfs image.sif",
+		Args:    cobra.ExactArgs(3),
+		PreRunE: c.initApp,
+		RunE: func(_ *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("while converting id: %w", err)
+			}
+
+			return c.app.SetName(args[2], uint32(id), args[1])
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
+
+// getSetGroup returns a command that sets the group of a data object.
+func (c *command) getSetGroup() *cobra.Command {
+	return &cobra.Command{
+		Use:   "group <id> <group_id> <sif_path>",
+		Short: "Set data object group",
+		Long: `Set the group of a data object in a SIF image. If group_id is 0, the data object
+is removed from its group.`,
+		Example: c.opts.rootPath + " set group 1 2 image.sif",
+		Args:    cobra.ExactArgs(3),
+		PreRunE: c.initApp,
+		RunE: func(_ *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("while converting id: %w", err)
+			}
+
+			groupID, err := strconv.ParseUint(args[1], 10, 32)
+			if err != nil {
+				return fmt.Errorf("while converting group id: %w", err)
+			}
+
+			return c.app.SetGroup(args[2], uint32(id), uint32(groupID))
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
+
+// getSetLink returns a command that sets the link of a data object.
+func (c *command) getSetLink() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link [flags] <id> <link_id> <sif_path>",
+		Short: "Set data object link",
+		Long: `Set the link of a data object in a SIF image. If link_id is 0, the link is
+removed.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " set link 3 1 image.sif",
+			c.opts.rootPath + " set link --group 3 1 image.sif",
+		}, "\n"),
+		Args: cobra.ExactArgs(3),
+	}
+
+	isGroup := cmd.Flags().Bool("group", false, "link_id is a group ID")
+
+	cmd.PreRunE = c.initApp
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		id, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("while converting id: %w", err)
+		}
+
+		linkID, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("while converting link id: %w", err)
+		}
+
+		return c.app.SetLink(args[2], uint32(id), uint32(linkID), *isGroup)
+	}
+
+	return cmd
+}
+
+// getSetLaunchScript returns a command that sets the launch script of an image.
+func (c *command) getSetLaunchScript() *cobra.Command {
+	return &cobra.Command{
+		Use:   "launch-script <script> <sif_path>",
+		Short: "Set image launch script",
+		Long: `Set the launch script of a SIF image. A newline is appended to script if not
+already present. If script is empty, the launch script is removed.`,
+		Example: c.opts.rootPath + " set launch-script '#!/usr/bin/env run-singularity' image.sif",
+		Args:    cobra.ExactArgs(2),
+		PreRunE: c.initApp,
+		RunE: func(_ *cobra.Command, args []string) error {
+			script := args[0]
+			if script != "" && !strings.HasSuffix(script, "\n") {
+				script += "\n"
+			}
+
+			return c.app.SetLaunchScript(args[1], script)
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
+
+// getSetID returns a command that sets the unique ID of an image.
+func (c *command) getSetID() *cobra.Command {
+	return &cobra.Command{
+		Use:   "id [id] <sif_path>",
+		Short: "Set image ID",
+		Long: `Set the unique ID of a SIF image. If id is not specified, a random ID is
+generated.`,
+		Example: strings.Join([]string{
+			c.opts.rootPath + " set id image.sif",
+			c.opts.rootPath + " set id de170c43-36ab-44a8-bca9-1ea1a070a274 image.sif",
+		}, "\n"),
+		Args:    cobra.RangeArgs(1, 2),
+		PreRunE: c.initApp,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return c.app.SetID(args[0], "")
+			}
+			return c.app.SetID(args[1], args[0])
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package siftool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeTestSignedSIF returns the path to a copy of a signed SIF from the test corpus.
+func makeTestSignedSIF(t *testing.T) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(corpus, "one-group-signed-dsse.sif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sif")
+
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_command_getSetName(t *testing.T) {
+	tests := []struct {
+		name string
+		opts commandOpts
+		path string
+	}{
+		{
+			name: "OK",
+			path: makeTestSIF(t, true),
+		},
+		{
+			name: "Signed",
+			path: makeTestSignedSIF(t),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getSetName()
+
+			runCommand(t, cmd, []string{"1", "name", tt.path}, nil)
+		})
+	}
+}
+
+func Test_command_getSetGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    commandOpts
+		groupID string
+	}{
+		{
+			name:    "OK",
+			groupID: "2",
+		},
+		{
+			name:    "NoGroup",
+			groupID: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getSetGroup()
+
+			runCommand(t, cmd, []string{"1", tt.groupID, makeTestSIF(t, true)}, nil)
+		})
+	}
+}
+
+func Test_command_getSetLink(t *testing.T) {
+	tests := []struct {
+		name string
+		opts commandOpts
+		args []string
+	}{
+		{
+			name: "OK",
+			args: []string{"1", "1"},
+		},
+		{
+			name: "Group",
+			args: []string{"--group", "1", "1"},
+		},
+		{
+			name: "NoLink",
+			args: []string{"1", "0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getSetLink()
+
+			runCommand(t, cmd, append(tt.args, makeTestSIF(t, true)), nil)
+		})
+	}
+}
+
+func Test_command_getSetLaunchScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   commandOpts
+		script string
+		path   string
+	}{
+		{
+			name:   "OK",
+			script: "#!/usr/bin/env run-singularity",
+			path:   makeTestSIF(t, true),
+		},
+		{
+			name:   "Empty",
+			script: "",
+			path:   makeTestSIF(t, true),
+		},
+		{
+			name:   "Signed",
+			script: "#!/usr/bin/env run-singularity",
+			path:   makeTestSignedSIF(t),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getSetLaunchScript()
+
+			runCommand(t, cmd, []string{tt.script, tt.path}, nil)
+		})
+	}
+}
+
+func Test_command_getSetID(t *testing.T) {
+	tests := []struct {
+		name string
+		opts commandOpts
+		args []string
+	}{
+		{
+			name: "OK",
+			args: []string{"de170c43-36ab-44a8-bca9-1ea1a070a274"},
+		},
+		{
+			name: "Random",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &command{opts: tt.opts}
+
+			cmd := c.getSetID()
+
+			runCommand(t, cmd, append(tt.args, makeTestSIF(t, true)), nil)
+		})
+	}
+}
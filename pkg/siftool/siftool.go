@@ -78,6 +78,13 @@ func AddCommands(cmd *cobra.Command, opts ...CommandOpt) error {
 		c.getAdd(),
 		c.getDel(),
 		c.getSetPrim(),
+		c.getRepack(),
+		c.getSet(),
+		c.getCopy(),
+		c.getExtract(),
+		c.getCheck(),
+		c.getRepair(),
+		c.getOCI(),
 	)
 
 	return nil
@@ -118,6 +118,62 @@ func TestAddCommands(t *testing.T) {
 			name: "SetPrim",
 			args: []string{"help", "setprim"},
 		},
+		{
+			name: "Repack",
+			args: []string{"help", "repack"},
+		},
+		{
+			name: "Set",
+			args: []string{"help", "set"},
+		},
+		{
+			name: "SetName",
+			args: []string{"help", "set", "name"},
+		},
+		{
+			name: "SetGroup",
+			args: []string{"help", "set", "group"},
+		},
+		{
+			name: "SetLink",
+			args: []string{"help", "set", "link"},
+		},
+		{
+			name: "Copy",
+			args: []string{"help", "copy"},
+		},
+		{
+			name: "Extract",
+			args: []string{"help", "extract"},
+		},
+		{
+			name: "SetLaunchScript",
+			args: []string{"help", "set", "launch-script"},
+		},
+		{
+			name: "SetID",
+			args: []string{"help", "set", "id"},
+		},
+		{
+			name: "Check",
+			args: []string{"help", "check"},
+		},
+		{
+			name: "Repair",
+			args: []string{"help", "repair"},
+		},
+		{
+			name: "OCI",
+			args: []string{"help", "oci"},
+		},
+		{
+			name: "OCIImport",
+			args: []string{"help", "oci", "import"},
+		},
+		{
+			name: "OCIExport",
+			args: []string{"help", "oci", "export"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -18,6 +18,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 
 	"github.com/apptainer/sif/v2/pkg/sif"
 )
@@ -46,6 +47,8 @@ type mountOpts struct {
 	stdout         io.Writer
 	stderr         io.Writer
 	squashfusePath string
+	arch           string
+	archSet        bool // arch specified by OptMountArch.
 }
 
 // MountOpt are used to specify mount options.
@@ -81,10 +84,38 @@ func OptMountSquashfusePath(path string) MountOpt {
 	}
 }
 
+// OptMountArch specifies arch as the CPU architecture of the primary system partition to mount.
+// The value of arch should be the architecture as represented by the Go runtime.
+func OptMountArch(arch string) MountOpt {
+	return func(mo *mountOpts) error {
+		mo.arch = arch
+		mo.archSet = true
+		return nil
+	}
+}
+
 var errUnsupportedFSType = errors.New("unrecognized filesystem type")
 
+// getPrimaryPartition returns the descriptor of the primary system partition in f for arch. If f
+// does not contain a primary system partition for arch, and fallback is true, but f contains
+// exactly one primary system partition, that partition is returned.
+func getPrimaryPartition(f *sif.FileImage, arch string, fallback bool) (sif.Descriptor, error) {
+	d, err := f.GetPrimaryPartition(arch)
+	if errors.Is(err, sif.ErrObjectNotFound) && fallback {
+		return f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	}
+	return d, err
+}
+
 // Mount mounts the primary system partition of the SIF file at path into mountPath.
 //
+// By default, the primary system partition for the CPU architecture of the running program
+// (runtime.GOARCH) is mounted. If the image does not contain a primary system partition for that
+// architecture, but contains exactly one primary system partition, that partition is mounted. To
+// select a different architecture, consider using OptMountArch. If an architecture is selected
+// using OptMountArch, and the image does not contain a primary system partition for that
+// architecture, an error wrapping sif.ErrObjectNotFound is returned.
+//
 // Mount may start one or more underlying processes. By default, stdout and stderr of these
 // processes is discarded. To modify this behavior, consider using OptMountStdout and/or
 // OptMountStderr.
@@ -94,6 +125,7 @@ var errUnsupportedFSType = errors.New("unrecognized filesystem type")
 func Mount(ctx context.Context, path, mountPath string, opts ...MountOpt) error {
 	mo := mountOpts{
 		squashfusePath: "squashfuse",
+		arch:           runtime.GOARCH,
 	}
 
 	for _, opt := range opts {
@@ -108,7 +140,7 @@ func Mount(ctx context.Context, path, mountPath string, opts ...MountOpt) error
 	}
 	defer func() { _ = f.UnloadContainer() }()
 
-	d, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	d, err := getPrimaryPartition(f, mo.arch, !mo.archSet)
 	if err != nil {
 		return fmt.Errorf("failed to get partition descriptor: %w", err)
 	}
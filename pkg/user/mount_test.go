@@ -0,0 +1,88 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package user
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+func Test_getPrimaryPartition(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		arch     string
+		fallback bool
+		wantID   uint32
+		wantErr  error
+	}{
+		{
+			name:   "Match",
+			path:   "two-archs.sif",
+			arch:   "amd64",
+			wantID: 3,
+		},
+		{
+			name:     "Fallback",
+			path:     "one-group.sif",
+			arch:     "amd64",
+			fallback: true,
+			wantID:   2,
+		},
+		{
+			name:    "NoFallback",
+			path:    "one-group.sif",
+			arch:    "amd64",
+			wantErr: sif.ErrObjectNotFound,
+		},
+		{
+			name:     "FallbackMultiple",
+			path:     "two-archs.sif",
+			arch:     "arm64",
+			fallback: true,
+			wantErr:  sif.ErrMultipleObjectsFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := sif.LoadContainerFromPath(filepath.Join(corpus, tt.path), sif.OptLoadWithFlag(os.O_RDONLY))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.UnloadContainer()
+
+			d, err := getPrimaryPartition(f, tt.arch, tt.fallback)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				if got, want := d.ID(), tt.wantID; got != want {
+					t.Errorf("got ID %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_Mount_ArchNotFound(t *testing.T) {
+	// The image contains only a 386 primary system partition, which must not be mounted when
+	// another architecture is requested explicitly.
+	err := Mount(context.Background(), filepath.Join(corpus, "one-group.sif"), t.TempDir(),
+		OptMountArch("arm64"),
+	)
+	if !errors.Is(err, sif.ErrObjectNotFound) {
+		t.Errorf("got error %v, want %v", err, sif.ErrObjectNotFound)
+	}
+}
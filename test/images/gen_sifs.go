@@ -142,6 +142,18 @@ func generateImages() error {
 		)
 	}
 
+	partPrimSysGroup2 := func() (sif.DescriptorInput, error) {
+		b, err := os.ReadFile(filepath.Join("..", "input", "root.ext3"))
+		if err != nil {
+			return sif.DescriptorInput{}, err
+		}
+
+		return sif.NewDescriptorInput(sif.DataPartition, bytes.NewReader(b),
+			sif.OptPartitionMetadata(sif.FsExt3, sif.PartPrimSys, "amd64"),
+			sif.OptGroupID(2),
+		)
+	}
+
 	images := []struct {
 		path     string
 		diFns    []func() (sif.DescriptorInput, error)
@@ -266,6 +278,16 @@ func generateImages() error {
 				integrity.OptSignWithEntity(e),
 			},
 		},
+
+		// Images with primary partitions for two architectures.
+		{
+			path: "two-archs.sif",
+			diFns: []func() (sif.DescriptorInput, error){
+				partSystem,
+				partPrimSys,
+				partPrimSysGroup2,
+			},
+		},
 	}
 
 	for _, image := range images {